@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/supreethrao/automated-rota-manager/pkg/httpclient"
+	"github.com/supreethrao/automated-rota-manager/pkg/localdb"
+)
+
+// rotaServer is the address of a running rota manager. The running rota manager holds its database, so commands go through
+// its API when it is set and only open the database themselves when it is not
+var rotaServer string
+
+func addServerFlag(command *cobra.Command) {
+	command.Flags().StringVar(&rotaServer, "server", "", "address of the running rota manager, such as http://localhost:9090, to go through its API rather than opening the database it holds")
+}
+
+func server() httpclient.Client {
+	return httpclient.New(rotaServer)
+}
+
+// openStore opens the database for commands run without --server, read only unless the command writes to it.
+// Badger locks the database while the rota manager is running, so this only works once it is stopped.
+func openStore(readOnly bool) (*localdb.LocalDB, error) {
+	open := localdb.GetHandle
+	if readOnly {
+		open = localdb.GetReadOnlyHandle
+	}
+
+	dbHandle, err := open()
+	if err != nil {
+		return nil, fmt.Errorf("%v. Use --server to go through the running rota manager instead", err)
+	}
+	return dbHandle, nil
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/supreethrao/automated-rota-manager/pkg/config"
+	"github.com/supreethrao/automated-rota-manager/pkg/rota"
+	"github.com/supreethrao/automated-rota-manager/pkg/scheduler"
+)
+
+var (
+	forecastTeam  string
+	forecastCount int
+)
+
+var forecastCmd = &cobra.Command{
+	Use:   "forecast",
+	Short: "Projects who is next on the rota without changing it",
+	RunE:  runForecast,
+}
+
+func init() {
+	forecastCmd.Flags().StringVarP(&forecastTeam, "team", "t", "", "team to forecast. Can be left out when only one team is configured")
+	forecastCmd.Flags().IntVarP(&forecastCount, "count", "c", 8, "number of picks to forecast")
+	addServerFlag(forecastCmd)
+	rootCmd.AddCommand(forecastCmd)
+}
+
+func runForecast(_ *cobra.Command, _ []string) error {
+	cfg, err := config.New(configFilePath)
+	if err != nil {
+		return err
+	}
+
+	teamConfig, err := configuredTeam(cfg, forecastTeam)
+	if err != nil {
+		return err
+	}
+
+	var slots []rota.ForecastSlot
+	if rotaServer != "" {
+		err = server().Call(http.MethodGet, []string{"teams", teamConfig.Name, "rota", "forecast"}, url.Values{"count": {strconv.Itoa(forecastCount)}}, &slots)
+	} else {
+		slots, err = forecastFromStore(teamConfig)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, slot := range slots {
+		picks := make([]string, 0, len(slot.Roles))
+		for ind, role := range slot.Roles {
+			picks = append(picks, fmt.Sprintf("%s: %s", role, slot.Picks[ind]))
+		}
+		status := "forecast"
+		if slot.Assigned {
+			status = "confirmed"
+		}
+		fmt.Printf("%s\t%s\t(%s)\n", slot.Date, strings.Join(picks, ", "), status)
+	}
+	return nil
+}
+
+func forecastFromStore(teamConfig config.TeamConfig) ([]rota.ForecastSlot, error) {
+	nextRun, err := scheduler.Cadence(teamConfig.CronSchedule)
+	if err != nil {
+		return nil, fmt.Errorf("team %s: %v", teamConfig.Name, err)
+	}
+
+	dbHandle, err := openStore(true)
+	if err != nil {
+		return nil, err
+	}
+	defer dbHandle.Close()
+
+	myTeam, err := newTeam(teamConfig, dbHandle)
+	if err != nil {
+		return nil, err
+	}
+	return myTeam.Forecast(forecastCount, nextRun)
+}
+
+// configuredTeam finds the team by name, falling back to the only team configured when no name is given
+func configuredTeam(cfg *config.ARMConfig, name string) (config.TeamConfig, error) {
+	if name == "" && len(cfg.Teams) == 1 {
+		return cfg.Teams[0], nil
+	}
+
+	for _, teamConfig := range cfg.Teams {
+		if teamConfig.Name == name {
+			return teamConfig, nil
+		}
+	}
+	return config.TeamConfig{}, fmt.Errorf("team %q is not configured. Pick one with --team", name)
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"github.com/supreethrao/automated-rota-manager/pkg/config"
+	"github.com/supreethrao/automated-rota-manager/pkg/rota"
+)
+
+var (
+	migrateTeam  string
+	migrateApply bool
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Moves members keyed by their name over to stable IDs, keeping their history",
+	RunE:  runMigrate,
+}
+
+func init() {
+	migrateCmd.Flags().StringVarP(&migrateTeam, "team", "t", "", "team to migrate. Can be left out when only one team is configured")
+	migrateCmd.Flags().BoolVar(&migrateApply, "apply", false, "rewrite the keys rather than only listing the members that would move")
+	addServerFlag(migrateCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
+
+func runMigrate(_ *cobra.Command, _ []string) error {
+	cfg, err := config.New(configFilePath)
+	if err != nil {
+		return err
+	}
+
+	teamConfig, err := configuredTeam(cfg, migrateTeam)
+	if err != nil {
+		return err
+	}
+
+	var migrations []rota.Migration
+	if rotaServer != "" {
+		method := http.MethodGet
+		if migrateApply {
+			method = http.MethodPost
+		}
+		err = server().Call(method, []string{"teams", teamConfig.Name, "migrate"}, nil, &migrations)
+	} else {
+		migrations, err = migrateFromStore(teamConfig)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(migrations) == 0 {
+		fmt.Println("Every member is already keyed by their ID")
+		return nil
+	}
+	for _, migration := range migrations {
+		fmt.Printf("%s\t-> %s\n", migration.Name, migration.ID)
+	}
+	if migrateApply {
+		fmt.Printf("Migrated %d members\n", len(migrations))
+	} else {
+		fmt.Println("Run again with --apply to migrate them")
+	}
+	return nil
+}
+
+// migrateFromStore only opens the database for writing when the changes are applied
+func migrateFromStore(teamConfig config.TeamConfig) ([]rota.Migration, error) {
+	dbHandle, err := openStore(!migrateApply)
+	if err != nil {
+		return nil, err
+	}
+	defer dbHandle.Close()
+
+	myTeam, err := newTeam(teamConfig, dbHandle)
+	if err != nil {
+		return nil, err
+	}
+	return myTeam.MigrateToIDs(migrateApply)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"github.com/supreethrao/automated-rota-manager/pkg/config"
+	"github.com/supreethrao/automated-rota-manager/pkg/rota"
+)
+
+var (
+	reconcileTeam  string
+	reconcileApply bool
+)
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Rebuilds the accrued days and latest picked days from the days recorded",
+	RunE:  runReconcile,
+}
+
+func init() {
+	reconcileCmd.Flags().StringVarP(&reconcileTeam, "team", "t", "", "team to reconcile. Can be left out when only one team is configured")
+	reconcileCmd.Flags().BoolVar(&reconcileApply, "apply", false, "replace the stored values with the ones rebuilt")
+	addServerFlag(reconcileCmd)
+	rootCmd.AddCommand(reconcileCmd)
+}
+
+func runReconcile(_ *cobra.Command, _ []string) error {
+	cfg, err := config.New(configFilePath)
+	if err != nil {
+		return err
+	}
+
+	teamConfig, err := configuredTeam(cfg, reconcileTeam)
+	if err != nil {
+		return err
+	}
+
+	var discrepancies []rota.Discrepancy
+	if rotaServer != "" {
+		method := http.MethodGet
+		if reconcileApply {
+			method = http.MethodPost
+		}
+		err = server().Call(method, []string{"teams", teamConfig.Name, "reconcile"}, nil, &discrepancies)
+	} else {
+		discrepancies, err = reconcileFromStore(teamConfig)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(discrepancies) == 0 {
+		fmt.Println("Everything is in step with the days recorded")
+		return nil
+	}
+	for _, discrepancy := range discrepancies {
+		field := discrepancy.Field
+		if discrepancy.Role != "" {
+			field += " as " + discrepancy.Role
+		}
+		fmt.Printf("%s\t%s\t- %s\t+ %s\n", discrepancy.Member, field, discrepancy.Stored, discrepancy.Derived)
+	}
+	if reconcileApply {
+		fmt.Printf("Fixed %d values\n", len(discrepancies))
+	} else {
+		fmt.Println("Run again with --apply to fix them")
+	}
+	return nil
+}
+
+// reconcileFromStore only opens the database for writing when the changes are applied
+func reconcileFromStore(teamConfig config.TeamConfig) ([]rota.Discrepancy, error) {
+	dbHandle, err := openStore(!reconcileApply)
+	if err != nil {
+		return nil, err
+	}
+	defer dbHandle.Close()
+
+	myTeam, err := newTeam(teamConfig, dbHandle)
+	if err != nil {
+		return nil, err
+	}
+	return myTeam.Reconcile(reconcileApply)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/supreethrao/automated-rota-manager/pkg/config"
+)
+
+var (
+	recordTeam   string
+	recordMember string
+	recordDate   string
+	recordRole   string
+)
+
+var recordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Records or corrects who covered the rota on a past date",
+	RunE:  runRecord,
+}
+
+func init() {
+	recordCmd.Flags().StringVarP(&recordTeam, "team", "t", "", "team to record the assignment in. Can be left out when only one team is configured")
+	recordCmd.Flags().StringVarP(&recordMember, "member", "m", "", "member who covered the date")
+	recordCmd.Flags().StringVarP(&recordDate, "date", "d", "", "date covered in the format DD-MM-YYYY")
+	recordCmd.Flags().StringVarP(&recordRole, "role", "r", "", "role covered. Defaults to the first role")
+	_ = recordCmd.MarkFlagRequired("member")
+	_ = recordCmd.MarkFlagRequired("date")
+	addServerFlag(recordCmd)
+	rootCmd.AddCommand(recordCmd)
+}
+
+func runRecord(_ *cobra.Command, _ []string) error {
+	date, err := time.Parse("02-01-2006", recordDate)
+	if err != nil {
+		return fmt.Errorf("invalid date %s. Date should be of the format DD-MM-YYYY", recordDate)
+	}
+
+	cfg, err := config.New(configFilePath)
+	if err != nil {
+		return err
+	}
+
+	teamConfig, err := configuredTeam(cfg, recordTeam)
+	if err != nil {
+		return err
+	}
+
+	if rotaServer != "" {
+		query := url.Values{"by": {"cli"}}
+		if recordRole != "" {
+			query.Set("role", recordRole)
+		}
+		if err := server().Call(http.MethodPost, []string{"teams", teamConfig.Name, "rota", "record", recordMember, date.Format("02-01-2006")}, query, nil); err != nil {
+			return err
+		}
+		fmt.Printf("Recorded %s on %s\n", recordMember, date.Format("02-01-2006"))
+		return nil
+	}
+
+	dbHandle, err := openStore(false)
+	if err != nil {
+		return err
+	}
+	defer dbHandle.Close()
+
+	myTeam, err := newTeam(teamConfig, dbHandle)
+	if err != nil {
+		return err
+	}
+
+	role := recordRole
+	if role == "" {
+		role = myTeam.Roles()[0]
+	}
+	member, err := myTeam.Resolve(recordMember)
+	if err != nil {
+		return err
+	}
+	if err := myTeam.RecordAssignmentForRole(role, member, date, "cli"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Recorded %s as %s on %s\n", member, role, date.Format("02-01-2006"))
+	return nil
+}
@@ -2,11 +2,13 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/supreethrao/automated-rota-manager/pkg/config"
 	"github.com/supreethrao/automated-rota-manager/pkg/helpers"
 	"github.com/supreethrao/automated-rota-manager/pkg/httpserver"
 	"github.com/supreethrao/automated-rota-manager/pkg/localdb"
+	"github.com/supreethrao/automated-rota-manager/pkg/metrics"
 	"github.com/supreethrao/automated-rota-manager/pkg/rota"
 	"github.com/supreethrao/automated-rota-manager/pkg/scheduler"
 	"github.com/supreethrao/automated-rota-manager/pkg/slackhandler"
@@ -14,6 +16,9 @@ import (
 	"log"
 )
 
+// deadlineCheckSchedule is how often the confirmation deadlines are checked
+const deadlineCheckSchedule = "@every 1m"
+
 var configFilePath string
 
 var rootCmd = &cobra.Command {
@@ -22,7 +27,7 @@ var rootCmd = &cobra.Command {
 }
 
 func init() {
-	rootCmd.Flags().StringVarP(&configFilePath, "config", "f", "/app/config/arm-config.yaml", "config file path")
+	rootCmd.PersistentFlags().StringVarP(&configFilePath, "config", "f", "/app/config/arm-config.yaml", "config file path")
 }
 
 func runRotaManager(_ *cobra.Command, _ []string) error {
@@ -37,7 +42,23 @@ func runRotaManager(_ *cobra.Command, _ []string) error {
 	}
 	defer dbHandle.Close()
 
-	myTeam := rota.NewTeam(cfg.TeamName, dbHandle)
+	slackToken := helpers.Getenv("SLACK_TOKEN", "unknown")
+	teams := make(map[string]httpserver.Team)
+
+	for _, teamConfig := range cfg.Teams {
+		myTeam, err := newTeam(teamConfig, dbHandle)
+		if err != nil {
+			return err
+		}
+
+		slackConfig := slackhandler.SlackConfig{
+			Token:    slackToken,
+			Channel:  teamConfig.SlackChannel,
+			UserName: cfg.SlackUserName,
+		}
+		metrics.Watch(myTeam)
+		teams[teamConfig.Name] = httpserver.Team{Rota: myTeam, Messager: slackhandler.NewMessager(slackConfig), CronSchedule: teamConfig.CronSchedule, IngressURL: teamConfig.IngressURL}
+	}
 
 	initContext := context.Background()
 	cancelContext, cancelFunc := context.WithCancel(initContext)
@@ -45,32 +66,94 @@ func runRotaManager(_ *cobra.Command, _ []string) error {
 
 	synGroup, synContext := errgroup.WithContext(cancelContext)
 
-	slackToken := helpers.Getenv("SLACK_TOKEN", "unknown")
-	slackConfig := slackhandler.SlackConfig{
-		Token:    slackToken,
-		Channel:  cfg.SlackChannel,
-		UserName: cfg.SlackUserName,
-	}
-	slackMessager := slackhandler.NewMessager(slackConfig)
+	for _, teamConfig := range cfg.Teams {
+		teamConfig := teamConfig
+		team := teams[teamConfig.Name]
 
-	synGroup.Go(func() error {
-		return httpserver.Start(synContext, slackMessager, myTeam)
-	})
+		synGroup.Go(func() error {
+			scheduledRotaPicker := scheduler.NewSchedule(teamConfig.CronSchedule, func() {
+				if isHoliday, whichOne := helpers.IsTodayHoliday(); isHoliday {
+					log.Printf("Today is %s and hence skipping the rota pick for %s \n", whichOne, teamConfig.Name)
+					metrics.CronRun(teamConfig.Name, metrics.CronHoliday)
+				} else {
+					team.Rota.PickNextPerson(synContext, team.Messager, teamConfig.IngressURL)
+				}
+			})
+			return scheduledRotaPicker.Schedule()
+		})
+
+		if teamConfig.Deadline() > 0 {
+			synGroup.Go(func() error {
+				// The pending picks are kept in the database, so deadlines that passed while the process was down are acted on once it is back
+				deadlineChecker := scheduler.NewSchedule(deadlineCheckSchedule, func() {
+					team.Rota.CheckDeadlines(synContext, team.Messager, teamConfig.IngressURL)
+				})
+				return deadlineChecker.Schedule()
+			})
+		}
+	}
 
 	synGroup.Go(func() error {
-		scheduledRotaPicker := scheduler.NewSchedule(cfg.CronSchedule, func() {
-			if isHoliday, whichOne := helpers.IsTodayHoliday(); isHoliday {
-				log.Printf("Today is %s and hence skipping the rota pick \n", whichOne)
-			} else {
-				myTeam.PickNextPerson(synContext, slackMessager, cfg.IngressURL)
-			}
-		})
-		return scheduledRotaPicker.Schedule()
+		return httpserver.Start(synContext, teams)
 	})
 
 	return synGroup.Wait()
 }
 
+// newTeam sets up the team's rota as configured
+func newTeam(teamConfig config.TeamConfig, dbHandle localdb.Store) (*rota.Team, error) {
+	strategy, err := rota.NewStrategy(teamConfig.Strategy, teamConfig.StrategySeed)
+	if err != nil {
+		return nil, fmt.Errorf("team %s: %v", teamConfig.Name, err)
+	}
+
+	requirement, err := rota.ParseRequirement(teamConfig.Requirement)
+	if err != nil {
+		return nil, fmt.Errorf("team %s: %v", teamConfig.Name, err)
+	}
+
+	rules := make([]rota.Rule, 0, len(teamConfig.Rules))
+	for _, ruleConfig := range teamConfig.Rules {
+		rule, err := rota.NewRule(ruleConfig.Type, ruleConfig.Members, ruleConfig.Tag)
+		if err != nil {
+			return nil, fmt.Errorf("team %s: %v", teamConfig.Name, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	costs, err := rota.NewCosts(teamConfig.Costs.Weekdays, teamConfig.Costs.PreHoliday, teamConfig.Costs.Dates)
+	if err != nil {
+		return nil, fmt.Errorf("team %s: %v", teamConfig.Name, err)
+	}
+
+	onboarding := rota.Onboarding{Seed: teamConfig.Onboarding.Seed, GraceCycles: teamConfig.Onboarding.GraceCycles}
+	if err := rota.CheckOnboarding(onboarding); err != nil {
+		return nil, fmt.Errorf("team %s: %v", teamConfig.Name, err)
+	}
+
+	cadence, err := scheduler.Cadence(teamConfig.CronSchedule)
+	if err != nil {
+		return nil, fmt.Errorf("team %s: %v", teamConfig.Name, err)
+	}
+
+	return rota.NewTeamWithSettings(teamConfig.Name, dbHandle, rota.Settings{
+		Strategy:             strategy,
+		Roles:                teamConfig.RoleNames(),
+		ShiftDays:            teamConfig.ShiftDays,
+		IsHoliday:            helpers.IsHoliday,
+		Requirement:          requirement,
+		Rules:                rules,
+		Costs:                costs,
+		ConfirmationDeadline: teamConfig.Deadline(),
+		OnDeadline:           teamConfig.OnDeadline,
+		Onboarding:           onboarding,
+		Cooldown:             teamConfig.Cooldown,
+		Seed:                 teamConfig.StrategySeed,
+		ExplainPicks:         teamConfig.ExplainPicks,
+		Cadence:              cadence,
+	}), nil
+}
+
 func Exec() error {
 	return rootCmd.Execute()
 }
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/supreethrao/automated-rota-manager/pkg/config"
+	"github.com/supreethrao/automated-rota-manager/pkg/rota"
+	"github.com/supreethrao/automated-rota-manager/pkg/scheduler"
+)
+
+var (
+	simulateTeam           string
+	simulateStrategy       string
+	simulateDays           int
+	simulateAbsenceRate    float64
+	simulateMaxAbsenceDays int
+	simulateSeed           int64
+)
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Runs the team's rota over a simulated year in memory and reports how fairly the days were spread",
+	RunE:  runSimulate,
+}
+
+func init() {
+	simulateCmd.Flags().StringVarP(&simulateTeam, "team", "t", "", "team to simulate. Can be left out when only one team is configured")
+	simulateCmd.Flags().StringVarP(&simulateStrategy, "strategy", "s", "", "strategy to simulate instead of the one configured for the team")
+	simulateCmd.Flags().IntVarP(&simulateDays, "days", "d", 365, "number of days to simulate")
+	simulateCmd.Flags().Float64Var(&simulateAbsenceRate, "absence-rate", 0.02, "chance of each member going out of office on any working day")
+	simulateCmd.Flags().IntVar(&simulateMaxAbsenceDays, "max-absence-days", 10, "longest out of office generated, in days")
+	simulateCmd.Flags().Int64Var(&simulateSeed, "seed", 1, "seed for the out of office generated, so that runs can be compared")
+	addServerFlag(simulateCmd)
+	rootCmd.AddCommand(simulateCmd)
+}
+
+func runSimulate(_ *cobra.Command, _ []string) error {
+	cfg, err := config.New(configFilePath)
+	if err != nil {
+		return err
+	}
+
+	teamConfig, err := configuredTeam(cfg, simulateTeam)
+	if err != nil {
+		return err
+	}
+
+	var report rota.SimulationReport
+	if rotaServer != "" {
+		err = server().Call(http.MethodGet, []string{"teams", teamConfig.Name, "rota", "simulate"}, url.Values{
+			"days":             {strconv.Itoa(simulateDays)},
+			"absence-rate":     {strconv.FormatFloat(simulateAbsenceRate, 'g', -1, 64)},
+			"max-absence-days": {strconv.Itoa(simulateMaxAbsenceDays)},
+			"seed":             {strconv.FormatInt(simulateSeed, 10)},
+			"strategy":         {simulateStrategy},
+		}, &report)
+	} else {
+		report, err = simulateFromStore(teamConfig)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d picks over %d days, %d roles left unfilled\n", report.Runs, simulateDays, report.Unfilled)
+	fmt.Printf("Spread of accrued days:\t%g\n", report.Spread)
+	fmt.Printf("Longest gap between picks:\t%d days (%s)\n", report.LongestGap, report.LongestGapMember)
+	fmt.Printf("Worst starvation:\t%d picks in a row passed over while in the office (%s)\n\n", report.WorstStarvation, report.StarvedMember)
+
+	fmt.Println("Member\tPicks\tAccrued\tAway\tLongest gap\tStarvation")
+	for _, member := range report.Members {
+		fmt.Printf("%s\t%d\t%g\t%d\t%d\t%d\n", member.Name, member.Picks, member.DaysAccrued, member.DaysAway, member.LongestGap, member.Starvation)
+	}
+	return nil
+}
+
+func simulateFromStore(teamConfig config.TeamConfig) (rota.SimulationReport, error) {
+	nextRun, err := scheduler.Cadence(teamConfig.CronSchedule)
+	if err != nil {
+		return rota.SimulationReport{}, fmt.Errorf("team %s: %v", teamConfig.Name, err)
+	}
+
+	dbHandle, err := openStore(true)
+	if err != nil {
+		return rota.SimulationReport{}, err
+	}
+	defer dbHandle.Close()
+
+	myTeam, err := newTeam(teamConfig, dbHandle)
+	if err != nil {
+		return rota.SimulationReport{}, err
+	}
+
+	// Every simulated pick would otherwise be logged
+	log.SetOutput(ioutil.Discard)
+	logrus.SetLevel(logrus.WarnLevel)
+
+	return myTeam.Simulate(nextRun, rota.SimulationOptions{
+		Start:          time.Now(),
+		Days:           simulateDays,
+		AbsenceRate:    simulateAbsenceRate,
+		MaxAbsenceDays: simulateMaxAbsenceDays,
+		Seed:           simulateSeed,
+		Strategy:       simulateStrategy,
+	})
+}
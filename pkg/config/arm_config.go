@@ -1,19 +1,100 @@
 package config
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
+	"strings"
+	"time"
 )
 
 type ARMConfig struct {
-	CronSchedule string	`yaml:"cron_schedule"`
-	TeamName string	`yaml:"team_name"`
-	IngressURL string	`yaml:"ingress_url"`
-	SlackUserName string `yaml:"slack_user_name"`
-	SlackChannel string	`yaml:"slack_channel"`
+	SlackUserName string       `yaml:"slack_user_name"`
+	IngressURL    string       `yaml:"ingress_url"`
+	Teams         []TeamConfig `yaml:"teams"`
+
+	// Single team configuration retained for deployments that predate the teams list
+	CronSchedule string `yaml:"cron_schedule"`
+	TeamName     string `yaml:"team_name"`
+	SlackChannel string `yaml:"slack_channel"`
+}
+
+type TeamConfig struct {
+	Name         string `yaml:"name"`
+	CronSchedule string `yaml:"cron_schedule"`
+	IngressURL   string `yaml:"ingress_url"`
+	SlackChannel string `yaml:"slack_channel"`
+	// Strategy is one of least-accrued (default), round-robin, least-recently-picked or fair-random
+	Strategy string `yaml:"strategy"`
+	// StrategySeed varies the fair random draw and settles the ties left in the order members are considered in
+	StrategySeed int64 `yaml:"strategy_seed"`
+	// SlotSize is the number of people picked for every slot, each covering a different role
+	SlotSize int      `yaml:"slot_size"`
+	Roles    []string `yaml:"roles"`
+	// ShiftDays is the number of days covered by each pick, such as 7 for a team picking weekly
+	ShiftDays int `yaml:"shift_days"`
+	// Requirement is what members' tags have to satisfy to be picked, such as "k8s-admin && !new-joiner"
+	Requirement string `yaml:"requirement"`
+	// Rules limit which members can be picked together or one after another
+	Rules []RuleConfig `yaml:"rules"`
+	// Costs weigh what each day of cover accrues. Every day accrues one by default
+	Costs CostConfig `yaml:"costs"`
+	// ConfirmationDeadline is how long a suggestion waits to be confirmed, such as 2h. Suggestions wait indefinitely when not set
+	ConfirmationDeadline string `yaml:"confirmation_deadline"`
+	// OnDeadline is either confirm (default), confirming the suggestion, or advance, suggesting the next eligible member
+	OnDeadline string `yaml:"on_deadline"`
+	// Onboarding is how new members join the rota
+	Onboarding OnboardingConfig `yaml:"onboarding"`
+	// Cooldown is the number of other people picked before the same person is picked again. Defaults to 2
+	Cooldown *int `yaml:"cooldown"`
+	// ExplainPicks adds how every member was considered to the Slack message announcing the pick
+	ExplainPicks bool `yaml:"explain_picks"`
+}
+
+// OnboardingConfig seeds a new member's accrued days from the minimum (default), mean or median of the team, or zero,
+// and leaves them out of the picks for the given number of slots after they join
+type OnboardingConfig struct {
+	Seed        string `yaml:"seed"`
+	GraceCycles int    `yaml:"grace_cycles"`
+}
+
+// CostConfig is what a day of cover accrues by day of the week, such as friday: 1.5, on the day before a bank holiday and on named dates in the DD-MM-YYYY format
+type CostConfig struct {
+	Weekdays   map[string]float64 `yaml:"weekdays"`
+	PreHoliday *float64           `yaml:"pre_holiday"`
+	Dates      map[string]float64 `yaml:"dates"`
+}
+
+// RuleConfig applies one of not_consecutive, not_together or not_same_week to the members listed and anyone with the tag
+type RuleConfig struct {
+	Type    string   `yaml:"type"`
+	Members []string `yaml:"members"`
+	Tag     string   `yaml:"tag"`
+}
+
+// Deadline is the confirmation deadline, or 0 when suggestions wait indefinitely
+func (team TeamConfig) Deadline() time.Duration {
+	deadline, _ := time.ParseDuration(team.ConfirmationDeadline)
+	return deadline
+}
+
+// RoleNames are the configured roles, or roles named after their position when only the slot size is set
+func (team TeamConfig) RoleNames() []string {
+	if len(team.Roles) > 0 {
+		return team.Roles
+	}
+
+	roles := []string{"primary", "secondary"}
+	for len(roles) < team.SlotSize {
+		roles = append(roles, fmt.Sprintf("backup-%d", len(roles)-1))
+	}
+	if team.SlotSize < 1 {
+		return roles[:1]
+	}
+	return roles[:team.SlotSize]
 }
 
-func New(filePath string) (*ARMConfig, error){
+func New(filePath string) (*ARMConfig, error) {
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		return nil, err
@@ -26,5 +107,68 @@ func New(filePath string) (*ARMConfig, error){
 		return nil, err
 	}
 
+	if len(cfg.Teams) == 0 && cfg.TeamName != "" {
+		cfg.Teams = []TeamConfig{{
+			Name:         cfg.TeamName,
+			CronSchedule: cfg.CronSchedule,
+			SlackChannel: cfg.SlackChannel,
+		}}
+	}
+
+	if err = cfg.validate(); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
+
+func (cfg *ARMConfig) validate() error {
+	if len(cfg.Teams) == 0 {
+		return fmt.Errorf("at least one team needs to be configured")
+	}
+
+	seen := make(map[string]bool)
+	for i := range cfg.Teams {
+		team := &cfg.Teams[i]
+		if team.Name == "" {
+			return fmt.Errorf("team %d in the config has no name", i+1)
+		}
+		// The name prefixes the team's keys and is part of its URLs
+		if strings.Contains(team.Name, "::") || strings.Contains(team.Name, "/") {
+			return fmt.Errorf("team name %s cannot contain '::' or '/'", team.Name)
+		}
+		if seen[team.Name] {
+			return fmt.Errorf("team %s is configured more than once", team.Name)
+		}
+		seen[team.Name] = true
+
+		if team.CronSchedule == "" {
+			return fmt.Errorf("team %s has no cron schedule", team.Name)
+		}
+		if len(team.Roles) > 0 && team.SlotSize > 0 && len(team.Roles) != team.SlotSize {
+			return fmt.Errorf("team %s has a slot size of %d but %d roles", team.Name, team.SlotSize, len(team.Roles))
+		}
+		roles := make(map[string]bool)
+		for _, role := range team.Roles {
+			if role == "" || roles[role] {
+				return fmt.Errorf("team %s has a blank or repeated role", team.Name)
+			}
+			roles[role] = true
+		}
+		if team.ConfirmationDeadline != "" {
+			if deadline, err := time.ParseDuration(team.ConfirmationDeadline); err != nil || deadline <= 0 {
+				return fmt.Errorf("team %s has an invalid confirmation deadline %q. Use a duration such as 2h", team.Name, team.ConfirmationDeadline)
+			}
+		}
+		if team.Cooldown != nil && *team.Cooldown < 0 {
+			return fmt.Errorf("team %s has a negative cooldown", team.Name)
+		}
+		if team.OnDeadline != "" && team.OnDeadline != "confirm" && team.OnDeadline != "advance" {
+			return fmt.Errorf("team %s has an invalid on_deadline %q. Use confirm or advance", team.Name, team.OnDeadline)
+		}
+		if team.IngressURL == "" {
+			team.IngressURL = cfg.IngressURL
+		}
+	}
+	return nil
+}
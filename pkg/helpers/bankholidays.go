@@ -6,7 +6,6 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -25,7 +24,7 @@ type event struct {
 	Bunting bool
 }
 
-var holidaysThisYear = map[string]string{}
+var holidays = map[string]string{}
 
 func init() {
 	resp, err := http.Get("https://www.gov.uk/bank-holidays.json")
@@ -40,14 +39,14 @@ func init() {
 	body, _ := ioutil.ReadAll(resp.Body)
 
 	placeHolder := map[string]locationSpecificHolidays{}
-	currentYear := strconv.Itoa(time.Now().Year())
 
+	// All the published years are kept so that dates beyond this year, such as in a multi day shift, can be checked
 	if er := json.Unmarshal(body, &placeHolder); er == nil {
 		for _, ev := range placeHolder[location].Events {
-			if strings.Contains(ev.Date, currentYear+"-") {
-				splitDate := strings.Split(ev.Date, "-")
+			splitDate := strings.Split(ev.Date, "-")
+			if len(splitDate) == 3 {
 				formattedSplitDate := []string{splitDate[2], splitDate[1], splitDate[0]}
-				holidaysThisYear[strings.Join(formattedSplitDate, "-")] = ev.Title
+				holidays[strings.Join(formattedSplitDate, "-")] = ev.Title
 			}
 		}
 	} else {
@@ -56,13 +55,14 @@ func init() {
 }
 
 func IsTodayHoliday() (bool, string) {
-	today := time.Now()
-	dateToday := today.Format("02-01-2006")
+	return IsHoliday(time.Now())
+}
 
-	if today.Weekday() == time.Saturday || today.Weekday() == time.Sunday {
+func IsHoliday(day time.Time) (bool, string) {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
 		return true, "Weekend"
 	}
 
-	val, ok := holidaysThisYear[dateToday]
+	val, ok := holidays[day.Format("02-01-2006")]
 	return ok, val
 }
@@ -0,0 +1,54 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the API of a running rota manager, such as http://localhost:9090
+type Client struct {
+	server string
+}
+
+func New(server string) Client {
+	return Client{server: strings.TrimSuffix(server, "/")}
+}
+
+// Call sends the request for the path, escaping each of its segments, and decodes the JSON responded with into result
+// when one is given. Responses other than a success are returned as an error holding what the server said.
+func (c Client) Call(method string, path []string, query url.Values, result interface{}) error {
+	segments := make([]string, 0, len(path))
+	for _, segment := range path {
+		segments = append(segments, url.PathEscape(segment))
+	}
+	endpoint := c.server + "/" + strings.Join(segments, "/")
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("unable to reach the rota manager at %s: %v", c.server, err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s %s: %s %s", method, endpoint, response.Status, strings.TrimSpace(string(body)))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(body, result)
+}
@@ -0,0 +1,64 @@
+package httpclient_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/supreethrao/automated-rota-manager/pkg/httpclient"
+)
+
+var _ = Describe("Tests for the http client", func() {
+	var (
+		server   *httptest.Server
+		received *http.Request
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			received = request
+			if request.URL.Query().Get("fail") != "" {
+				writer.WriteHeader(http.StatusNotFound)
+				_, _ = fmt.Fprintln(writer, "unknown team my team")
+				return
+			}
+			_, _ = writer.Write([]byte(`{"Runs": 3}`))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Escapes every segment of the path and decodes the JSON responded with", func() {
+		var report struct{ Runs int }
+		err := httpclient.New(server.URL+"/").Call(http.MethodGet, []string{"teams", "my team", "rota", "simulate"}, url.Values{"days": {"30"}}, &report)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(received.Method).To(Equal(http.MethodGet))
+		Expect(received.URL.EscapedPath()).To(Equal("/teams/my%20team/rota/simulate"))
+		Expect(received.URL.Query().Get("days")).To(Equal("30"))
+		Expect(report.Runs).To(Equal(3))
+	})
+
+	It("Leaves the response alone when there is nothing to decode it into", func() {
+		Expect(httpclient.New(server.URL).Call(http.MethodPost, []string{"teams", "my team", "reconcile"}, nil, nil)).To(Succeed())
+		Expect(received.Method).To(Equal(http.MethodPost))
+	})
+
+	It("Returns what the server said when the request fails", func() {
+		err := httpclient.New(server.URL).Call(http.MethodGet, []string{"teams", "my team"}, url.Values{"fail": {"true"}}, nil)
+		Expect(err).To(MatchError(ContainSubstring("404 Not Found unknown team my team")))
+	})
+
+	It("Says which rota manager could not be reached", func() {
+		address := server.URL
+		server.Close()
+
+		err := httpclient.New(address).Call(http.MethodGet, []string{"teams"}, nil, nil)
+		Expect(err).To(MatchError(ContainSubstring("unable to reach the rota manager at " + address)))
+	})
+})
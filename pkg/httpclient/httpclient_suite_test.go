@@ -0,0 +1,13 @@
+package httpclient_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestHttpClientLogic(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Test Suite for rota manager http client")
+}
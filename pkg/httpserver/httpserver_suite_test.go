@@ -0,0 +1,13 @@
+package httpserver_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestHttpServerLogic(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Test Suite for rota manager endpoints")
+}
@@ -0,0 +1,47 @@
+package httpserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/supreethrao/automated-rota-manager/pkg/metrics"
+)
+
+// instrumentedRouter times every request against the route it was registered with, so that requests for different
+// teams and members are counted together
+type instrumentedRouter struct {
+	*httprouter.Router
+}
+
+func (r instrumentedRouter) GET(path string, handle httprouter.Handle) {
+	r.Router.GET(path, instrument(http.MethodGet, path, handle))
+}
+
+func (r instrumentedRouter) POST(path string, handle httprouter.Handle) {
+	r.Router.POST(path, instrument(http.MethodPost, path, handle))
+}
+
+func (r instrumentedRouter) DELETE(path string, handle httprouter.Handle) {
+	r.Router.DELETE(path, instrument(http.MethodDelete, path, handle))
+}
+
+func instrument(method, route string, handle httprouter.Handle) httprouter.Handle {
+	return func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: writer, status: http.StatusOK}
+		handle(recorder, request, params)
+		metrics.ObserveRequest(route, method, recorder.status, time.Since(start))
+	}
+}
+
+// statusRecorder keeps the status code written to the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
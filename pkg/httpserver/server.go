@@ -6,33 +6,117 @@ import (
 	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/supreethrao/automated-rota-manager/pkg/helpers"
 	"github.com/supreethrao/automated-rota-manager/pkg/rota"
+	"github.com/supreethrao/automated-rota-manager/pkg/scheduler"
 	"github.com/supreethrao/automated-rota-manager/pkg/slackhandler"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
 
 const (
-	httpServerPort      = 9090
-	shutdownGracePeriod = 15 * time.Second
+	httpServerPort       = 9090
+	shutdownGracePeriod  = 15 * time.Second
+	defaultForecastCount = 8
+
+	defaultSimulationDays = 365
+	defaultAbsenceRate    = 0.02
+	defaultMaxAbsenceDays = 10
+	defaultSimulationSeed = 1
 )
 
-func Start(_ context.Context, slackMessager *slackhandler.Messager, myTeam *rota.Team) error {
-	router := httprouter.New()
+// Team bundles what the endpoints need to serve a single team
+type Team struct {
+	Rota         *rota.Team
+	Messager     *slackhandler.Messager
+	CronSchedule string
+	IngressURL   string
+}
 
-	router.POST("/members/:name", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-		if err := myTeam.Add(params.ByName("name")); err != nil {
-			_, _ = fmt.Fprint(writer)
+type teamHandle func(http.ResponseWriter, *http.Request, httprouter.Params, Team)
+
+func Start(_ context.Context, teams map[string]Team) error {
+	httpServer := &http.Server{Addr: fmt.Sprintf(":%d", httpServerPort), Handler: NewHandler(teams)}
+	errChan := make(chan error, 1)
+
+	go func() {
+		err := httpServer.ListenAndServe()
+		errChan <- err
+	}()
+
+	return gracefulShutdown(httpServer, errChan)
+}
+
+// NewHandler routes the endpoints of the teams served
+func NewHandler(teams map[string]Team) http.Handler {
+	router := instrumentedRouter{httprouter.New()}
+
+	router.GET("/teams", func(writer http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+		writer.Header().Set("Content-Type", "application/json")
+		teamNames := make([]string, 0, len(teams))
+		for name := range teams {
+			teamNames = append(teamNames, name)
 		}
+		sort.Strings(teamNames)
+		jsonData, _ := json.Marshal(teamNames)
+		_, _ = writer.Write(jsonData)
 	})
 
-	router.GET("/members", func(writer http.ResponseWriter, request *http.Request, _ httprouter.Params) {
+	router.POST("/teams/:team/members/:name", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		var eligibleFrom time.Time
+		if from := request.URL.Query().Get("eligible_from"); from != "" {
+			var err error
+			if eligibleFrom, err = time.Parse("02-01-2006", from); err != nil {
+				writer.WriteHeader(http.StatusBadRequest)
+				_, _ = writer.Write([]byte("Invalid date format. eligible_from should be in the format DD-MM-YYYY \n"))
+				return
+			}
+		}
+
+		if err := team.Rota.AddEligibleFrom(params.ByName("name"), eligibleFrom); err != nil {
+			_, _ = fmt.Fprint(writer)
+		}
+	}))
+
+	router.POST("/teams/:team/members", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		var profile rota.Profile
+		if err := json.NewDecoder(request.Body).Decode(&profile); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte(fmt.Sprintf("Invalid profile. Send a JSON body such as {\"DisplayName\": \"Jane Doe\", \"SlackUserID\": \"U123\"} - %v \n", err)))
+			return
+		}
+
+		saved, err := team.Rota.SaveProfile(profile)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to save the profile %v", err)))
+			return
+		}
 		writer.Header().Set("Content-Type", "application/json")
-		history, err := myTeam.RotaHistory()
+		jsonData, _ := json.Marshal(saved)
+		_, _ = writer.Write(jsonData)
+	}))
+
+	router.GET("/teams/:team/profiles", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		writer.Header().Set("Content-Type", "application/json")
+		profiles, err := team.Rota.Profiles()
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to get the profiles %v", err)))
+			return
+		}
+		jsonData, _ := json.Marshal(profiles)
+		_, _ = writer.Write(jsonData)
+	}))
+
+	router.GET("/teams/:team/members", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		writer.Header().Set("Content-Type", "application/json")
+		history, err := team.Rota.RotaHistoryForRole(role(request, team))
 		if err != nil {
 			writer.WriteHeader(http.StatusInternalServerError)
 			_, _ = writer.Write([]byte(fmt.Sprintf("unable to get team memers %v", err)))
@@ -40,15 +124,97 @@ func Start(_ context.Context, slackMessager *slackhandler.Messager, myTeam *rota
 		}
 		jsonData, _ := json.Marshal(history)
 		_, _ = writer.Write(jsonData)
-	})
+	}))
+
+	router.POST("/teams/:team/members/:name/weight/:weight", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		weight, err := strconv.ParseFloat(params.ByName("weight"), 64)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte("Invalid weight. Weight should be a number such as 0.5 \n"))
+			return
+		}
+
+		if err := team.Rota.SetWeight(params.ByName("name"), weight); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+		writer.WriteHeader(http.StatusAccepted)
+	}))
 
-	router.DELETE("/members/:name", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-		if err := myTeam.Remove(params.ByName("name")); err != nil {
+	router.POST("/teams/:team/members/:name/tags/:tags", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		if err := team.Rota.SetTags(params.ByName("name"), strings.Split(params.ByName("tags"), ",")); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+
+	router.DELETE("/teams/:team/members/:name/tags", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		if err := team.Rota.SetTags(params.ByName("name"), []string{}); err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+
+	router.GET("/teams/:team/members/:name/tags", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		writer.Header().Set("Content-Type", "application/json")
+		tags, err := team.Rota.Tags(params.ByName("name"))
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to get the tags %v", err)))
+			return
+		}
+		jsonData, _ := json.Marshal(tags)
+		_, _ = writer.Write(jsonData)
+	}))
+
+	router.DELETE("/teams/:team/members/:name", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		if err := team.Rota.Remove(params.ByName("name")); err != nil {
 			_, _ = fmt.Fprint(writer)
 		}
-	})
+	}))
+
+	router.POST("/teams/:team/members/:name/pause", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		var resumeOn time.Time
+		if until := request.URL.Query().Get("until"); until != "" {
+			var err error
+			if resumeOn, err = time.Parse("02-01-2006", until); err != nil {
+				writer.WriteHeader(http.StatusBadRequest)
+				_, _ = writer.Write([]byte("Invalid date format. until should be in the format DD-MM-YYYY \n"))
+				return
+			}
+		}
+
+		if err := team.Rota.Pause(params.ByName("name"), resumeOn); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to pause the member %v", err)))
+		}
+	}))
+
+	router.POST("/teams/:team/members/:name/resume", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		if err := team.Rota.Resume(params.ByName("name")); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to resume the member %v", err)))
+		}
+	}))
+
+	router.GET("/teams/:team/archived", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		writer.Header().Set("Content-Type", "application/json")
+		archived, err := team.Rota.Archived()
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to get the archived members %v", err)))
+			return
+		}
+		jsonData, _ := json.Marshal(archived)
+		_, _ = writer.Write(jsonData)
+	}))
 
-	router.POST("/outofoffice/:name/:from/:to", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+	router.POST("/teams/:team/outofoffice/:name/:from/:to", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
 		fromDate, errFrom := time.Parse("02-01-2006", params.ByName("from"))
 		toDate, errTo := time.Parse("02-01-2006", params.ByName("to"))
 
@@ -65,40 +231,111 @@ func Start(_ context.Context, slackMessager *slackhandler.Messager, myTeam *rota
 			return
 		}
 
-		if setError := myTeam.SetOutOfOffice(params.ByName("name"), fromDate, toDate); setError != nil {
+		if setError := team.Rota.SetOutOfOffice(params.ByName("name"), fromDate, toDate); setError != nil {
 			writer.WriteHeader(http.StatusInternalServerError)
 			// Need to do error mapping here
 			_, _ = fmt.Fprintln(writer, setError)
 		} else {
 			writer.WriteHeader(http.StatusCreated)
 		}
-	})
+	}))
 
-	router.GET("/outofoffice", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-		outOfOffice, err := myTeam.GetTeamOutOfOffice()
+	router.GET("/teams/:team/outofoffice", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		outOfOffice, err := team.Rota.GetTeamOutOfOffice()
 		if err != nil {
 			writer.WriteHeader(http.StatusInternalServerError)
 			_, _ = writer.Write([]byte(fmt.Sprintf("unable to get team out of office due to error %v", err)))
 			return
 		}
 		_, _ = writer.Write(outOfOffice)
-	})
+	}))
 
-	router.GET("/outofoffice/:name", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-		_, _ = writer.Write(myTeam.GetOutOfOffice(params.ByName("name")))
-	})
+	router.GET("/teams/:team/outofoffice/:name", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		_, _ = writer.Write(team.Rota.GetOutOfOffice(params.ByName("name")))
+	}))
 
-	router.GET("/rota/next", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
-		nextPerson, err := myTeam.Next()
+	router.GET("/teams/:team/rota/next", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		selection, err := team.Rota.Next()
 		if err != nil {
 			writer.WriteHeader(http.StatusInternalServerError)
 			_, _ = writer.Write([]byte(fmt.Sprintf("unable to get pick next person on rota %v", err)))
 			return
 		}
-		_, _ = fmt.Fprintf(writer, "The person picked today is: %s. \n", nextPerson)
-	})
+		if request.URL.Query().Get("explain") == "true" {
+			writer.Header().Set("Content-Type", "application/json")
+			jsonData, _ := json.Marshal(selection)
+			_, _ = writer.Write(jsonData)
+			return
+		}
+		if len(selection.Roles) == 1 {
+			_, _ = fmt.Fprintf(writer, "The person picked today is: %s. \n", selection.Primary())
+		} else {
+			for ind, role := range selection.Roles {
+				_, _ = fmt.Fprintf(writer, "The %s picked today is: %s. \n", role, selection.Picks[ind])
+			}
+		}
+		for _, skip := range selection.Skipped {
+			_, _ = fmt.Fprintf(writer, "Skipped %s as %s: %s \n", skip.Member, skip.Role, skip.Reason)
+		}
+	}))
+
+	router.GET("/teams/:team/rota/forecast", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		count := defaultForecastCount
+		if countParam := request.URL.Query().Get("count"); countParam != "" {
+			parsedCount, err := strconv.Atoi(countParam)
+			if err != nil || parsedCount < 1 {
+				writer.WriteHeader(http.StatusBadRequest)
+				_, _ = writer.Write([]byte("Invalid count. Count should be a positive number such as 8 \n"))
+				return
+			}
+			count = parsedCount
+		}
+
+		nextRun, err := scheduler.Cadence(team.CronSchedule)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to read the rota schedule %v", err)))
+			return
+		}
 
-	router.GET("/rota/confirm/:name/:date", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		writer.Header().Set("Content-Type", "application/json")
+		slots, err := team.Rota.Forecast(count, nextRun)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to forecast the rota %v", err)))
+			return
+		}
+		jsonData, _ := json.Marshal(slots)
+		_, _ = writer.Write(jsonData)
+	}))
+
+	router.GET("/teams/:team/rota/simulate", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		options, err := simulationOptions(request)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+
+		nextRun, err := scheduler.Cadence(team.CronSchedule)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to read the rota schedule %v", err)))
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		report, err := team.Rota.Simulate(nextRun, options)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to simulate the rota %v", err)))
+			return
+		}
+		jsonData, _ := json.Marshal(report)
+		_, _ = writer.Write(jsonData)
+	}))
+
+	router.GET("/teams/:team/rota/confirm/:name/:date", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
 		personPickedToday := params.ByName("name")
 
 		if time.Now().Format("02-01-2006") != params.ByName("date") {
@@ -106,51 +343,337 @@ func Start(_ context.Context, slackMessager *slackhandler.Messager, myTeam *rota
 			return
 		}
 
-		if isHoliday, whichOne := helpers.IsTodayHoliday(); isHoliday {
+		if isHoliday, whichOne := team.Rota.IsHoliday(time.Now()); isHoliday {
 			writer.WriteHeader(http.StatusForbidden)
 			_, _ = writer.Write([]byte(fmt.Sprintf("Cheeky attempt to pick a person on a holiday. Not happening as today is %s \n", whichOne)))
 			return
 		}
 
-		if err := myTeam.SetPersonPickedForToday(personPickedToday); err == nil {
-			_ = slackMessager.SendMessage(fmt.Sprintf("The person picked today is confirmed to be: %s \n", personPickedToday))
+		if err := team.Rota.SetPersonPickedForRole(role(request, team), personPickedToday, actor(request)); err == nil {
+			_ = team.Messager.SendMessage(fmt.Sprintf("The person picked today is confirmed to be: %s%s \n", personPickedToday, asRole(request)))
 			writer.WriteHeader(http.StatusAccepted)
 		} else {
 			writer.WriteHeader(http.StatusInternalServerError)
 			_, _ = fmt.Fprintln(writer, err)
 		}
-	})
+	}))
+
+	router.POST("/teams/:team/rota/record/:name/:date", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		date, err := time.Parse("02-01-2006", params.ByName("date"))
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte("Invalid date. Date should be of the format DD-MM-YYYY \n"))
+			return
+		}
 
-	router.GET("/rota/override/:name", func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		if err := team.Rota.RecordAssignmentForRole(role(request, team), params.ByName("name"), date, actor(request)); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+
+	router.DELETE("/teams/:team/rota/today", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		cancelled, err := team.Rota.CancelTodayForRole(role(request, team), actor(request))
+		if err != nil {
+			writer.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+		_ = team.Messager.SendMessage(fmt.Sprintf("Correction: %s is no longer on the rota today%s. A new pick is to follow \n", cancelled, asRole(request)))
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+
+	router.GET("/teams/:team/rota/decline/:name/:date", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		declining := params.ByName("name")
+
+		if time.Now().Format("02-01-2006") != params.ByName("date") {
+			_, _ = writer.Write([]byte("Illegal decline. Date has to be today"))
+			return
+		}
+
+		reason := request.URL.Query().Get("reason")
+		nextPerson, err := team.Rota.DeclineForRole(role(request, team), declining, reason, actor(request))
+		if err != nil {
+			writer.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+		_ = team.Messager.SendMessage(team.Rota.DeclineMessage(team.IngressURL, role(request, team), declining, reason, nextPerson))
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+
+	router.GET("/teams/:team/declines", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		writer.Header().Set("Content-Type", "application/json")
+		declines, err := team.Rota.Declines()
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to get the declines %v", err)))
+			return
+		}
+		jsonData, _ := json.Marshal(declines)
+		_, _ = writer.Write(jsonData)
+	}))
+
+	router.GET("/teams/:team/rota/override/:name", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
 		personToOverrideWith := params.ByName("name")
 
-		if isHoliday, whichOne := helpers.IsTodayHoliday(); isHoliday {
+		if isHoliday, whichOne := team.Rota.IsHoliday(time.Now()); isHoliday {
 			writer.WriteHeader(http.StatusForbidden)
 			_, _ = writer.Write([]byte(fmt.Sprintf("Cheeky attempt to override picking a person on a holiday. Not happening as today is %s \n", whichOne)))
 			return
 		}
 
-		if err := myTeam.OverridePersonPickedForToday(personToOverrideWith); err == nil {
-			_ = slackMessager.SendMessage(fmt.Sprintf("The rota pick for today was overridden. It's now: %s \n", personToOverrideWith))
+		if err := team.Rota.OverridePersonPickedForRole(role(request, team), personToOverrideWith, actor(request)); err == nil {
+			_ = team.Messager.SendMessage(fmt.Sprintf("The rota pick for today was overridden. It's now: %s%s \n", personToOverrideWith, asRole(request)))
 			writer.WriteHeader(http.StatusAccepted)
 		} else {
 			_, _ = fmt.Fprintln(writer, err)
 		}
-	})
+	}))
+
+	router.POST("/teams/:team/swaps/:from/:fromDate/:to/:toDate", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		fromDate, errFrom := time.Parse("02-01-2006", params.ByName("fromDate"))
+		toDate, errTo := time.Parse("02-01-2006", params.ByName("toDate"))
+		if errFrom != nil || errTo != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte("Invalid dates. Dates should be of the format DD-MM-YYYY \n"))
+			return
+		}
+
+		swapRequest, err := team.Rota.RequestSwap(role(request, team), params.ByName("from"), fromDate, params.ByName("to"), toDate, actor(request))
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+		_ = team.Messager.SendMessage(team.Rota.SwapRequestMessage(team.IngressURL, swapRequest))
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusCreated)
+		jsonData, _ := json.Marshal(swapRequest)
+		_, _ = writer.Write(jsonData)
+	}))
+
+	router.GET("/teams/:team/swaps", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		writer.Header().Set("Content-Type", "application/json")
+		swapRequests, err := team.Rota.SwapRequests()
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to get the swap requests %v", err)))
+			return
+		}
+		jsonData, _ := json.Marshal(swapRequests)
+		_, _ = writer.Write(jsonData)
+	}))
+
+	router.GET("/teams/:team/swaps/:id/accept", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		id, err := strconv.ParseUint(params.ByName("id"), 10, 64)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte("Invalid swap request id \n"))
+			return
+		}
+
+		swapRequest, err := team.Rota.AcceptSwap(id, actor(request))
+		if err != nil {
+			writer.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+		_ = team.Messager.SendMessage(fmt.Sprintf("%s and %s have swapped. %s is now on %s and %s on %s \n", swapRequest.From, swapRequest.To, swapRequest.To, swapRequest.FromDate, swapRequest.From, swapRequest.ToDate))
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+
+	router.GET("/teams/:team/swaps/:id/reject", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		id, err := strconv.ParseUint(params.ByName("id"), 10, 64)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte("Invalid swap request id \n"))
+			return
+		}
+
+		swapRequest, err := team.Rota.RejectSwap(id, actor(request))
+		if err != nil {
+			writer.WriteHeader(http.StatusConflict)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+		_ = team.Messager.SendMessage(fmt.Sprintf("%s turned down swapping with %s \n", swapRequest.To, swapRequest.From))
+		writer.WriteHeader(http.StatusAccepted)
+	}))
+
+	router.GET("/teams/:team/ledger", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		writer.Header().Set("Content-Type", "application/json")
+		entries, err := team.Rota.Ledger()
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to get the rota ledger %v", err)))
+			return
+		}
+		jsonData, _ := json.Marshal(entries)
+		_, _ = writer.Write(jsonData)
+	}))
+
+	router.GET("/teams/:team/ledger/:name", withMember(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		writer.Header().Set("Content-Type", "application/json")
+		entries, err := team.Rota.LedgerOfIndividual(params.ByName("name"))
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			_, _ = writer.Write([]byte(fmt.Sprintf("unable to get the rota ledger %v", err)))
+			return
+		}
+		jsonData, _ := json.Marshal(entries)
+		_, _ = writer.Write(jsonData)
+	}))
+
+	router.GET("/teams/:team/reconcile", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		reconcile(writer, team, false)
+	}))
+
+	router.POST("/teams/:team/reconcile", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		reconcile(writer, team, true)
+	}))
+
+	router.GET("/teams/:team/migrate", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		migrate(writer, team, false)
+	}))
+
+	router.POST("/teams/:team/migrate", withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		migrate(writer, team, true)
+	}))
 
 	router.GET("/metrics", func(writer http.ResponseWriter, request *http.Request, _ httprouter.Params) {
 		promhttp.Handler().ServeHTTP(writer, request)
 	})
 
-	httpServer := &http.Server{Addr: fmt.Sprintf(":%d", httpServerPort), Handler: router}
-	errChan := make(chan error, 1)
+	return router.Router
+}
 
-	go func() {
-		err := httpServer.ListenAndServe()
-		errChan <- err
-	}()
+// withTeam resolves the team named in the route and responds with not found for teams this process does not serve
+func withTeam(teams map[string]Team, handle teamHandle) httprouter.Handle {
+	return func(writer http.ResponseWriter, request *http.Request, params httprouter.Params) {
+		team, ok := teams[params.ByName("team")]
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(writer, "unknown team %s \n", params.ByName("team"))
+			return
+		}
+		handle(writer, request, params, team)
+	}
+}
 
-	return gracefulShutdown(httpServer, errChan)
+// withMember also resolves the member named in the route to their ID, and responds with not found for names that are not a member
+func withMember(teams map[string]Team, handle teamHandle) httprouter.Handle {
+	return withTeam(teams, func(writer http.ResponseWriter, request *http.Request, params httprouter.Params, team Team) {
+		member, err := team.Rota.Resolve(params.ByName("name"))
+		if err != nil {
+			writer.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintln(writer, err)
+			return
+		}
+
+		resolved := make(httprouter.Params, 0, len(params))
+		for _, param := range params {
+			if param.Key == "name" {
+				param.Value = member
+			}
+			resolved = append(resolved, param)
+		}
+		handle(writer, request, resolved, team)
+	})
+}
+
+// reconcile lists the counters that disagree with the days recorded, fixing them when apply is set
+func reconcile(writer http.ResponseWriter, team Team, apply bool) {
+	writer.Header().Set("Content-Type", "application/json")
+	discrepancies, err := team.Rota.Reconcile(apply)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		_, _ = writer.Write([]byte(fmt.Sprintf("unable to reconcile the rota %v", err)))
+		return
+	}
+	jsonData, _ := json.Marshal(discrepancies)
+	_, _ = writer.Write(jsonData)
+}
+
+// migrate lists the members still keyed by their name, moving them over to their IDs when apply is set
+func migrate(writer http.ResponseWriter, team Team, apply bool) {
+	writer.Header().Set("Content-Type", "application/json")
+	migrations, err := team.Rota.MigrateToIDs(apply)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		_, _ = writer.Write([]byte(fmt.Sprintf("unable to migrate the rota %v", err)))
+		return
+	}
+	jsonData, _ := json.Marshal(migrations)
+	_, _ = writer.Write(jsonData)
+}
+
+// simulationOptions reads the simulation asked for from the query parameters `days`, `absence-rate`, `max-absence-days`,
+// `seed` and `strategy`, starting from now
+func simulationOptions(request *http.Request) (rota.SimulationOptions, error) {
+	query := request.URL.Query()
+	options := rota.SimulationOptions{
+		Start:          time.Now(),
+		Days:           defaultSimulationDays,
+		AbsenceRate:    defaultAbsenceRate,
+		MaxAbsenceDays: defaultMaxAbsenceDays,
+		Seed:           defaultSimulationSeed,
+	}
+
+	var err error
+	if days := query.Get("days"); days != "" {
+		if options.Days, err = strconv.Atoi(days); err != nil || options.Days < 1 {
+			return options, fmt.Errorf("invalid days %s. Days should be a positive number such as 365", days)
+		}
+	}
+	if rate := query.Get("absence-rate"); rate != "" {
+		if options.AbsenceRate, err = strconv.ParseFloat(rate, 64); err != nil || options.AbsenceRate < 0 || options.AbsenceRate > 1 {
+			return options, fmt.Errorf("invalid absence rate %s. The rate should be between 0 and 1 such as 0.02", rate)
+		}
+	}
+	if maxDays := query.Get("max-absence-days"); maxDays != "" {
+		if options.MaxAbsenceDays, err = strconv.Atoi(maxDays); err != nil {
+			return options, fmt.Errorf("invalid max absence days %s. It should be a number such as 10", maxDays)
+		}
+	}
+	if seed := query.Get("seed"); seed != "" {
+		if options.Seed, err = strconv.ParseInt(seed, 10, 64); err != nil {
+			return options, fmt.Errorf("invalid seed %s. The seed should be a number", seed)
+		}
+	}
+	if options.Strategy = query.Get("strategy"); options.Strategy != "" {
+		if _, err := rota.NewStrategy(options.Strategy, 0); err != nil {
+			return options, err
+		}
+	}
+	return options, nil
+}
+
+// role is the team role named by the `role` query parameter, defaulting to the team's first role
+func role(request *http.Request, team Team) string {
+	if role := request.URL.Query().Get("role"); role != "" {
+		return role
+	}
+	return team.Rota.Roles()[0]
+}
+
+// asRole names the role in messages when one was asked for
+func asRole(request *http.Request) string {
+	if role := request.URL.Query().Get("role"); role != "" {
+		return " as " + role
+	}
+	return ""
+}
+
+// actor identifies who made the change for the ledger. Callers can name themselves with the `by` query parameter
+func actor(request *http.Request) string {
+	if by := request.URL.Query().Get("by"); by != "" {
+		return by
+	}
+	return request.RemoteAddr
 }
 
 func gracefulShutdown(httpServer *http.Server, serverError chan error) error {
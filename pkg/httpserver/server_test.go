@@ -0,0 +1,179 @@
+package httpserver_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/supreethrao/automated-rota-manager/pkg/httpclient"
+	"github.com/supreethrao/automated-rota-manager/pkg/httpserver"
+	"github.com/supreethrao/automated-rota-manager/pkg/localdb"
+	"github.com/supreethrao/automated-rota-manager/pkg/rota"
+	"github.com/supreethrao/automated-rota-manager/pkg/scheduler"
+	"github.com/supreethrao/automated-rota-manager/pkg/slackhandler"
+)
+
+// The endpoints are called through the same client as the commands run with --server
+var _ = Describe("Tests for the endpoints", func() {
+	var (
+		server   *httptest.Server
+		client   httpclient.Client
+		dbHandle localdb.Store
+		myTeam   *rota.Team
+	)
+
+	BeforeEach(func() {
+		dbHandle = localdb.NewMemoryStore()
+		cadence, err := scheduler.Cadence("0 9 * * 1-5")
+		Expect(err).ToNot(HaveOccurred())
+		myTeam = rota.NewTeamWithSettings("my team", dbHandle, rota.Settings{Cadence: cadence})
+		for _, member := range []string{"Jane Doe", "person2", "person3"} {
+			Expect(myTeam.Add(member)).To(Succeed())
+		}
+
+		server = httptest.NewServer(httpserver.NewHandler(map[string]httpserver.Team{
+			"my team": {Rota: myTeam, Messager: slackhandler.NewMessager(slackhandler.SlackConfig{}), CronSchedule: "0 9 * * 1-5"},
+		}))
+		client = httpclient.New(server.URL)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Forecasting the rota", func() {
+		It("Projects the picks asked for without confirming any", func() {
+			var slots []rota.ForecastSlot
+			err := client.Call(http.MethodGet, []string{"teams", "my team", "rota", "forecast"}, url.Values{"count": {"3"}}, &slots)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(slots).To(HaveLen(3))
+			picked := make([]string, 0, len(slots))
+			for _, slot := range slots {
+				Expect(slot.Assigned).To(BeFalse())
+				picked = append(picked, slot.Picks[0])
+			}
+			Expect(picked).To(ConsistOf("jane-doe", "person2", "person3"))
+		})
+	})
+
+	Context("Recording past assignments", func() {
+		It("Records the member named in any form making their ID", func() {
+			threeDaysAgo := time.Now().AddDate(0, 0, -3).Format("02-01-2006")
+			err := client.Call(http.MethodPost, []string{"teams", "my team", "rota", "record", "Jane Doe", threeDaysAgo}, url.Values{"by": {"cli"}}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			ledger, err := myTeam.Ledger()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ledger).To(HaveLen(1))
+			Expect(ledger[0].Member).To(Equal("jane-doe"))
+			Expect(ledger[0].Date).To(Equal(threeDaysAgo))
+			Expect(ledger[0].Actor).To(Equal("cli"))
+		})
+
+		It("Turns down members who are not in the team and dates it cannot read", func() {
+			err := client.Call(http.MethodPost, []string{"teams", "my team", "rota", "record", "nobody", time.Now().AddDate(0, 0, -3).Format("02-01-2006")}, nil, nil)
+			Expect(err).To(MatchError(ContainSubstring("404 Not Found")))
+
+			err = client.Call(http.MethodPost, []string{"teams", "my team", "rota", "record", "person2", "2026-10-01"}, nil, nil)
+			Expect(err).To(MatchError(ContainSubstring("400 Bad Request Invalid date")))
+		})
+	})
+
+	Context("Swapping shifts", func() {
+		It("Proposes trading today's shift for one forecast ahead and accepts it", func() {
+			Expect(myTeam.SetPersonPickedForToday("jane-doe", "tester")).To(Succeed())
+
+			var slots []rota.ForecastSlot
+			err := client.Call(http.MethodGet, []string{"teams", "my team", "rota", "forecast"}, url.Values{"count": {"2"}}, &slots)
+			Expect(err).ToNot(HaveOccurred())
+			forecast := slots[len(slots)-1]
+			Expect(forecast.Picks[0]).ToNot(Equal("jane-doe"))
+
+			var swapRequest rota.SwapRequest
+			err = client.Call(http.MethodPost, []string{"teams", "my team", "swaps", "jane-doe", time.Now().Format("02-01-2006"), forecast.Picks[0], forecast.Date}, nil, &swapRequest)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(swapRequest.Status).To(Equal(rota.SwapPending))
+
+			err = client.Call(http.MethodGet, []string{"teams", "my team", "swaps", strconv.FormatUint(swapRequest.ID, 10), "accept"}, url.Values{"by": {forecast.Picks[0]}}, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			forecastDay, err := time.Parse("02-01-2006", forecast.Date)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(myTeam.PersonPickedOnTheDay(time.Now())).To(Equal(forecast.Picks[0]))
+			Expect(myTeam.PersonPickedOnTheDay(forecastDay)).To(Equal("jane-doe"))
+
+			var swapRequests []rota.SwapRequest
+			Expect(client.Call(http.MethodGet, []string{"teams", "my team", "swaps"}, nil, &swapRequests)).To(Succeed())
+			Expect(swapRequests).To(HaveLen(1))
+			Expect(swapRequests[0].Status).To(Equal(rota.SwapAccepted))
+			Expect(swapRequests[0].RespondedBy).To(Equal(forecast.Picks[0]))
+		})
+	})
+
+	Context("Reconciling the counters", func() {
+		It("Lists the counters out of step and only fixes them when posted to", func() {
+			Expect(myTeam.SetPersonPickedForToday("person2", "tester")).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person2"), []byte("01-01-2020"))).To(Succeed())
+
+			var discrepancies []rota.Discrepancy
+			Expect(client.Call(http.MethodGet, []string{"teams", "my team", "reconcile"}, nil, &discrepancies)).To(Succeed())
+			Expect(discrepancies).To(ConsistOf(rota.Discrepancy{Member: "person2", Field: "LatestPickedDay", Stored: "01-01-2020", Derived: time.Now().Format("02-01-2006")}))
+			Expect(client.Call(http.MethodGet, []string{"teams", "my team", "reconcile"}, nil, &discrepancies)).To(Succeed())
+			Expect(discrepancies).ToNot(BeEmpty())
+
+			Expect(client.Call(http.MethodPost, []string{"teams", "my team", "reconcile"}, nil, &discrepancies)).To(Succeed())
+			Expect(discrepancies).ToNot(BeEmpty())
+			Expect(client.Call(http.MethodGet, []string{"teams", "my team", "reconcile"}, nil, &discrepancies)).To(Succeed())
+			Expect(discrepancies).To(BeEmpty())
+		})
+	})
+
+	Context("Migrating members to their IDs", func() {
+		It("Lists the members keyed by their name and only moves them when posted to", func() {
+			Expect(dbHandle.Write(myTeam.TeamKey(), []byte("members:\n- Jane Doe\n- person2\n- person3\n"))).To(Succeed())
+
+			var migrations []rota.Migration
+			Expect(client.Call(http.MethodGet, []string{"teams", "my team", "migrate"}, nil, &migrations)).To(Succeed())
+			Expect(migrations).To(Equal([]rota.Migration{{Name: "Jane Doe", ID: "jane-doe"}}))
+			Expect(myTeam.List()).To(ContainElement("Jane Doe"))
+
+			Expect(client.Call(http.MethodPost, []string{"teams", "my team", "migrate"}, nil, &migrations)).To(Succeed())
+			Expect(migrations).To(HaveLen(1))
+			Expect(myTeam.List()).To(Equal([]string{"jane-doe", "person2", "person3"}))
+		})
+	})
+
+	Context("Simulating the rota", func() {
+		It("Reports how the team's rota spreads over the days asked for", func() {
+			var report rota.SimulationReport
+			err := client.Call(http.MethodGet, []string{"teams", "my team", "rota", "simulate"}, url.Values{"days": {"30"}, "strategy": {rota.RoundRobin}}, &report)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Runs).To(BeNumerically(">", 15))
+			Expect(report.Unfilled).To(BeZero())
+			Expect(report.Members).To(HaveLen(3))
+
+			ledger, err := myTeam.Ledger()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ledger).To(BeEmpty())
+		})
+
+		It("Turns down options it cannot simulate", func() {
+			err := client.Call(http.MethodGet, []string{"teams", "my team", "rota", "simulate"}, url.Values{"days": {"0"}}, nil)
+			Expect(err).To(MatchError(ContainSubstring("400 Bad Request invalid days 0")))
+
+			err = client.Call(http.MethodGet, []string{"teams", "my team", "rota", "simulate"}, url.Values{"strategy": {"alphabetical"}}, nil)
+			Expect(err).To(MatchError(ContainSubstring("400 Bad Request")))
+		})
+
+		It("Responds with not found for teams not served", func() {
+			err := client.Call(http.MethodGet, []string{"teams", "other team", "rota", "simulate"}, nil, nil)
+			Expect(err).To(MatchError(ContainSubstring("404 Not Found unknown team other team")))
+		})
+	})
+})
@@ -1,6 +1,8 @@
 package keys
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -16,11 +18,85 @@ func (key *Keys) AccruedDaysCounterKey(memberName string) string {
 	return key.rootPrefix + "::member::" + memberName
 }
 
+func (key *Keys) AccruedDaysCounterForRoleKey(memberName string, role string) string {
+	return key.AccruedDaysCounterKey(memberName) + "::role::" + role
+}
+
+func (key *Keys) WeightKey(memberName string) string {
+	return key.rootPrefix + "::weight::" + memberName
+}
+
+func (key *Keys) TagsKey(memberName string) string {
+	return key.rootPrefix + "::tags::" + memberName
+}
+
+// SeedKey holds the accrued days the member joined with, which are not accounted for by the days they covered
+func (key *Keys) SeedKey(memberName string) string {
+	return key.rootPrefix + "::seed::" + memberName
+}
+
+func (key *Keys) SeedForRoleKey(memberName string, role string) string {
+	return key.SeedKey(memberName) + "::role::" + role
+}
+
+// GraceKey holds when the member joined and the grace they were given before they can be picked
+func (key *Keys) GraceKey(memberName string) string {
+	return key.rootPrefix + "::grace::" + memberName
+}
+
+// StatePrefix is the prefix of the lifecycle state of every member who is not active
+func (key *Keys) StatePrefix() string {
+	return key.rootPrefix + "::state::"
+}
+
+func (key *Keys) StateKey(memberName string) string {
+	return key.StatePrefix() + memberName
+}
+
+func (key *Keys) ProfileKey(memberID string) string {
+	return key.rootPrefix + "::profile::" + memberID
+}
+
 func (key *Keys) PersonPickedOnDayKey(whichDay time.Time) string {
 	formattedDay := whichDay.Format("02-01-2006")
 	return key.rootPrefix + "::" + formattedDay
 }
 
+func (key *Keys) PersonPickedOnDayForRoleKey(whichDay time.Time, role string) string {
+	return key.PersonPickedOnDayKey(whichDay) + "::role::" + role
+}
+
+// DayKeysPrefix is shared by the keys of the people picked on each day, along with every other key of the team
+func (key *Keys) DayKeysPrefix() string {
+	return key.rootPrefix + "::"
+}
+
+// ParsePersonPickedOnDayKey reads the day and role back from a key of the person picked on the day.
+// The role is empty for the first role. It returns false for any other key.
+func (key *Keys) ParsePersonPickedOnDayKey(dayKey string) (time.Time, string, bool) {
+	if !strings.HasPrefix(dayKey, key.DayKeysPrefix()) {
+		return time.Time{}, "", false
+	}
+
+	rest := strings.TrimPrefix(dayKey, key.DayKeysPrefix())
+	if len(rest) < len("02-01-2006") {
+		return time.Time{}, "", false
+	}
+	day, err := time.Parse("02-01-2006", rest[:len("02-01-2006")])
+	if err != nil {
+		return time.Time{}, "", false
+	}
+
+	switch rest = rest[len("02-01-2006"):]; {
+	case rest == "":
+		return day, "", true
+	case strings.HasPrefix(rest, "::role::"):
+		return day, strings.TrimPrefix(rest, "::role::"), true
+	default:
+		return time.Time{}, "", false
+	}
+}
+
 func (key *Keys) LatestDayPickedKey(memberName string) string {
 	return key.rootPrefix + "::latest-day::" + memberName
 }
@@ -29,11 +105,52 @@ func (key *Keys) LatestCronRunKey() string {
 	return key.rootPrefix + "::latest-cron"
 }
 
+func (key *Keys) DeclinedPrefix() string {
+	return key.rootPrefix + "::declined::"
+}
+
+func (key *Keys) DeclinedKey(whichDay time.Time, role string) string {
+	return key.DeclinedPrefix() + whichDay.Format("02-01-2006") + "::" + role
+}
+
+func (key *Keys) DeclineCounterKey(memberName string) string {
+	return key.rootPrefix + "::declines::" + memberName
+}
+
+func (key *Keys) PendingPickKey(role string) string {
+	return key.rootPrefix + "::pending::" + role
+}
+
 func (key *Keys) OutOfOfficeKey(memberName string) (string, string) {
 	keyBase := key.rootPrefix + "::out_of_office::" + memberName
 	return keyBase + "::from_date", keyBase + "::to_date"
 }
 
+func (key *Keys) LedgerPrefix() string {
+	return key.rootPrefix + "::ledger::"
+}
+
+// LedgerEntryKey zero pads the sequence so that entries are listed in the order they were recorded
+func (key *Keys) LedgerEntryKey(sequence uint64) string {
+	return key.LedgerPrefix() + fmt.Sprintf("%020d", sequence)
+}
+
+func (key *Keys) LedgerSequenceKey() string {
+	return key.rootPrefix + "::ledger-sequence"
+}
+
+func (key *Keys) SwapRequestPrefix() string {
+	return key.rootPrefix + "::swap::"
+}
+
+func (key *Keys) SwapRequestKey(id uint64) string {
+	return key.SwapRequestPrefix() + fmt.Sprintf("%020d", id)
+}
+
+func (key *Keys) SwapSequenceKey() string {
+	return key.rootPrefix + "::swap-sequence"
+}
+
 func NewKey(rootPrefix string) Keys {
 	return Keys{rootPrefix}
 }
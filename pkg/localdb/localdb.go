@@ -14,12 +14,14 @@ const (
 	DefaultDBLocation string = "/badger/data"
 )
 
-var sequences = make(map[string]*badger.Sequence)
-
 var localDB *LocalDB
 
 type LocalDB struct {
 	db *badger.DB
+
+	// sequences are shared by the HTTP handlers and the scheduled jobs writing through the handle
+	sequenceLock sync.Mutex
+	sequences    map[string]*badger.Sequence
 }
 
 func GetHandle() (*LocalDB, error) {
@@ -36,6 +38,33 @@ func GetHandleFromLocation(dbLocation string) (*LocalDB, error) {
 		return localDB, nil
 	}
 
+	dbPtr, err := open(dbLocation, false)
+	if err != nil {
+		return nil, err
+	}
+	localDB = &LocalDB{
+		db:        dbPtr,
+		sequences: make(map[string]*badger.Sequence),
+	}
+
+	return localDB, nil
+}
+
+// GetReadOnlyHandle opens the database without taking it over, so that any number of readers can share it.
+// Writes through the handle fail. Badger still refuses to open the database while a process has it open for writing.
+func GetReadOnlyHandle() (*LocalDB, error) {
+	return GetReadOnlyHandleFromLocation(DefaultDBLocation)
+}
+
+func GetReadOnlyHandleFromLocation(dbLocation string) (*LocalDB, error) {
+	dbPtr, err := open(dbLocation, true)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalDB{db: dbPtr, sequences: make(map[string]*badger.Sequence)}, nil
+}
+
+func open(dbLocation string, readOnly bool) (*badger.DB, error) {
 	info, err := os.Stat(dbLocation)
 	if err != nil {
 		if isNotPresent := os.IsNotExist(err); isNotPresent {
@@ -51,16 +80,13 @@ func GetHandleFromLocation(dbLocation string) (*LocalDB, error) {
 	badgerOpt := badger.DefaultOptions
 	badgerOpt.Dir = dbLocation
 	badgerOpt.ValueDir = dbLocation
+	badgerOpt.ReadOnly = readOnly
 
 	dbPtr, err := badger.Open(badgerOpt)
 	if err != nil {
 		return nil, fmt.Errorf("not able to initialise DB: %v", err)
 	}
-	localDB = &LocalDB{
-		db: dbPtr,
-	}
-
-	return localDB, nil
+	return dbPtr, nil
 }
 
 func (l *LocalDB) Write(key string, data []byte) error {
@@ -70,6 +96,11 @@ func (l *LocalDB) Write(key string, data []byte) error {
 }
 
 func (l *LocalDB) MultiWrite(multiData map[string][]byte) error {
+	return l.MultiWriteAndRemove(multiData, nil)
+}
+
+// MultiWriteAndRemove writes and removes the keys in a single transaction
+func (l *LocalDB) MultiWriteAndRemove(multiData map[string][]byte, removals []string) error {
 	return l.db.Update(func(txn *badger.Txn) error {
 		for key, val := range multiData {
 			err := txn.Set([]byte(key), val)
@@ -77,6 +108,11 @@ func (l *LocalDB) MultiWrite(multiData map[string][]byte) error {
 				return err
 			}
 		}
+		for _, key := range removals {
+			if err := txn.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 }
@@ -115,6 +151,25 @@ func (l *LocalDB) ListKeys() []string {
 	return keys
 }
 
+func (l *LocalDB) ReadWithPrefix(prefix string) (map[string][]byte, error) {
+	data := make(map[string][]byte)
+	err := l.db.View(func(txn *badger.Txn) error {
+		iterator := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer iterator.Close()
+
+		for iterator.Seek([]byte(prefix)); iterator.ValidForPrefix([]byte(prefix)); iterator.Next() {
+			item := iterator.Item()
+			value, copyError := item.ValueCopy(nil)
+			if copyError != nil {
+				return copyError
+			}
+			data[string(item.KeyCopy(nil))] = value
+		}
+		return nil
+	})
+	return data, err
+}
+
 func (l *LocalDB) Remove(key string) error {
 	return l.db.Update(func(txn *badger.Txn) error {
 		return txn.Delete([]byte(key))
@@ -122,38 +177,53 @@ func (l *LocalDB) Remove(key string) error {
 }
 
 func (l *LocalDB) NewSeq(memberName string) error {
-	if _, ok := sequences[memberName]; !ok {
-		if seq, err := l.db.GetSequence([]byte(memberName), 1); err == nil {
-			sequences[memberName] = seq
-			return nil
-		} else {
-			return err
-		}
+	l.sequenceLock.Lock()
+	defer l.sequenceLock.Unlock()
+
+	if _, ok := l.sequences[memberName]; ok {
+		log.Printf("sequence key %s already exists", memberName)
+		return nil
 	}
-	log.Printf("sequence key %s already exists", memberName)
-	return nil
+	_, err := l.sequence(memberName)
+	return err
 }
 
 func (l *LocalDB) NextSeq(memberName string) (uint64, error) {
-	if seq, ok := sequences[memberName]; ok {
-		if next, err := seq.Next(); err != nil {
-			return 0, fmt.Errorf("Unable to obtain the next sequence")
-		} else {
-			return next, nil
-		}
-	} else {
-		if err := l.NewSeq(memberName); err == nil {
-			if next, err := sequences[memberName].Next(); err == nil {
-				return next, nil
-			} else {
-				return 0, fmt.Errorf("Unable to obtain the next sequence")
-			}
-		} else {
-			return 0, fmt.Errorf("Unable to obtain the next sequence")
-		}
+	l.sequenceLock.Lock()
+	defer l.sequenceLock.Unlock()
+
+	seq, err := l.sequence(memberName)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to obtain the next sequence")
 	}
+	next, err := seq.Next()
+	if err != nil {
+		return 0, fmt.Errorf("Unable to obtain the next sequence")
+	}
+	return next, nil
 }
 
+// sequence leases the sequence from the database the first time it is used. The caller holds the sequence lock
+func (l *LocalDB) sequence(memberName string) (*badger.Sequence, error) {
+	if seq, ok := l.sequences[memberName]; ok {
+		return seq, nil
+	}
+	seq, err := l.db.GetSequence([]byte(memberName), 1)
+	if err != nil {
+		return nil, err
+	}
+	l.sequences[memberName] = seq
+	return seq, nil
+}
+
+// Close releases the sequences leased before closing the database
 func (l *LocalDB) Close() {
+	l.sequenceLock.Lock()
+	for memberName, seq := range l.sequences {
+		_ = seq.Release()
+		delete(l.sequences, memberName)
+	}
+	l.sequenceLock.Unlock()
+
 	_ = l.db.Close()
 }
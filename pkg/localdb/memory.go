@@ -0,0 +1,123 @@
+package localdb
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger"
+)
+
+// overlaySequenceStart keeps sequences handed out by an overlay well clear of the ones stored underneath,
+// so that anything keyed by them sorts after what is already stored
+const overlaySequenceStart uint64 = 1 << 48
+
+// Store is what the rota needs from the database
+type Store interface {
+	Read(key string) ([]byte, error)
+	ReadWithPrefix(prefix string) (map[string][]byte, error)
+	Write(key string, data []byte) error
+	MultiWrite(multiData map[string][]byte) error
+	MultiWriteAndRemove(multiData map[string][]byte, removals []string) error
+	Remove(key string) error
+	NextSeq(name string) (uint64, error)
+}
+
+// MemoryStore keeps everything in memory. When it is an overlay, reads fall through to the store underneath
+// while writes and removals stay in memory, leaving the store underneath untouched.
+type MemoryStore struct {
+	lock      sync.Mutex
+	base      Store
+	data      map[string][]byte
+	removed   map[string]bool
+	sequences map[string]uint64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewOverlay(nil)
+}
+
+func NewOverlay(base Store) *MemoryStore {
+	return &MemoryStore{
+		base:      base,
+		data:      make(map[string][]byte),
+		removed:   make(map[string]bool),
+		sequences: make(map[string]uint64),
+	}
+}
+
+func (m *MemoryStore) Read(key string) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if value, ok := m.data[key]; ok {
+		return append([]byte{}, value...), nil
+	}
+	if m.removed[key] || m.base == nil {
+		return nil, badger.ErrKeyNotFound
+	}
+	return m.base.Read(key)
+}
+
+func (m *MemoryStore) ReadWithPrefix(prefix string) (map[string][]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	data := make(map[string][]byte)
+	if m.base != nil {
+		baseData, err := m.base.ReadWithPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range baseData {
+			if !m.removed[key] {
+				data[key] = value
+			}
+		}
+	}
+
+	for key, value := range m.data {
+		if strings.HasPrefix(key, prefix) {
+			data[key] = append([]byte{}, value...)
+		}
+	}
+	return data, nil
+}
+
+func (m *MemoryStore) Write(key string, data []byte) error {
+	return m.MultiWrite(map[string][]byte{key: data})
+}
+
+func (m *MemoryStore) MultiWrite(multiData map[string][]byte) error {
+	return m.MultiWriteAndRemove(multiData, nil)
+}
+
+func (m *MemoryStore) MultiWriteAndRemove(multiData map[string][]byte, removals []string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for key, val := range multiData {
+		m.data[key] = append([]byte{}, val...)
+		delete(m.removed, key)
+	}
+	for _, key := range removals {
+		delete(m.data, key)
+		m.removed[key] = true
+	}
+	return nil
+}
+
+func (m *MemoryStore) Remove(key string) error {
+	return m.MultiWriteAndRemove(nil, []string{key})
+}
+
+func (m *MemoryStore) NextSeq(name string) (uint64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	next, ok := m.sequences[name]
+	if !ok && m.base != nil {
+		next = overlaySequenceStart
+	}
+	m.sequences[name] = next + 1
+	return next, nil
+}
@@ -1,17 +1,179 @@
 package metrics
 
 import (
+	"strconv"
+	"sync"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CronPicked  = "picked"
+	CronNobody  = "nobody"
+	CronHoliday = "holiday"
+	CronFailed  = "failed"
+)
+
+var picksSuggested = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rota_picks_suggested_total",
+		Help: "Number of people suggested for a role, waiting to be confirmed",
+	},
+	[]string{"team", "role"},
+)
+
+var confirms = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rota_confirms_total",
+		Help: "Number of picks confirmed",
+	},
+	[]string{"team", "role"},
+)
+
+var overrides = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rota_overrides_total",
+		Help: "Number of picks overridden",
+	},
+	[]string{"team", "role"},
 )
 
-var memberCounter = prometheus.NewCounterVec(
+var cancellations = prometheus.NewCounterVec(
 	prometheus.CounterOpts{
-		Name: "rota_counter",
-		Help: "Metrics which keep track of days each person was picked",
+		Name: "rota_cancellations_total",
+		Help: "Number of assignments cancelled",
 	},
-	[]string{"name", "date"},
+	[]string{"team", "role"},
 )
 
+var cronRuns = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rota_cron_runs_total",
+		Help: "Number of scheduled picks by outcome: picked, nobody, holiday or failed",
+	},
+	[]string{"team", "outcome"},
+)
+
+var slackSendFailures = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rota_slack_send_failures_total",
+		Help: "Number of Slack messages that could not be sent",
+	},
+	[]string{"channel"},
+)
+
+var requestDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "rota_http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests by route",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"route", "method", "code"},
+)
+
+var accruedDays = prometheus.NewDesc(
+	"rota_member_accrued_days",
+	"Days accrued by each member in each role",
+	[]string{"team", "role", "member"},
+	nil,
+)
+
+var unconfirmedToday = prometheus.NewDesc(
+	"rota_unconfirmed_today",
+	"1 while nobody is confirmed for the role on a working day, 0 once someone is or on a holiday",
+	[]string{"team", "role"},
+	nil,
+)
+
+// Source is a team reporting the days accrued by its members, by role and then member, and the roles nobody is confirmed for today
+type Source interface {
+	Name() string
+	AccruedByRole() (map[string]map[string]float64, error)
+	UnconfirmedToday() (map[string]bool, error)
+}
+
+// teamCollector reads the teams' databases when scraped, so that members who leave drop out of the metrics
+// and a new day starts out unconfirmed without anything having to reset it
+type teamCollector struct {
+	lock    sync.Mutex
+	sources []Source
+}
+
+func (c *teamCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- accruedDays
+	descs <- unconfirmedToday
+}
+
+func (c *teamCollector) Collect(metrics chan<- prometheus.Metric) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, source := range c.sources {
+		accrued, err := source.AccruedByRole()
+		if err != nil {
+			logrus.Errorf("unable to read the days accrued by team %s: %v", source.Name(), err)
+		}
+		for role, members := range accrued {
+			for member, days := range members {
+				metrics <- prometheus.MustNewConstMetric(accruedDays, prometheus.GaugeValue, days, source.Name(), role, member)
+			}
+		}
+
+		unconfirmed, err := source.UnconfirmedToday()
+		if err != nil {
+			logrus.Errorf("unable to read whether team %s is confirmed for today: %v", source.Name(), err)
+		}
+		for role, isUnconfirmed := range unconfirmed {
+			value := 0.0
+			if isUnconfirmed {
+				value = 1
+			}
+			metrics <- prometheus.MustNewConstMetric(unconfirmedToday, prometheus.GaugeValue, value, source.Name(), role)
+		}
+	}
+}
+
+var teams = &teamCollector{}
+
 func init() {
-	prometheus.MustRegister(memberCounter)
+	prometheus.MustRegister(picksSuggested, confirms, overrides, cancellations, cronRuns, slackSendFailures, requestDuration, teams)
+}
+
+// Watch reports the days accrued by the team's members and the roles unconfirmed today on every scrape
+func Watch(source Source) {
+	teams.lock.Lock()
+	defer teams.lock.Unlock()
+	teams.sources = append(teams.sources, source)
+}
+
+// PickSuggested counts the suggestion waiting to be confirmed
+func PickSuggested(team, role string) {
+	picksSuggested.WithLabelValues(team, role).Inc()
+}
+
+func Confirmed(team, role string) {
+	confirms.WithLabelValues(team, role).Inc()
+}
+
+func Overridden(team, role string) {
+	overrides.WithLabelValues(team, role).Inc()
+}
+
+func Cancelled(team, role string) {
+	cancellations.WithLabelValues(team, role).Inc()
+}
+
+func CronRun(team, outcome string) {
+	cronRuns.WithLabelValues(team, outcome).Inc()
+}
+
+func SlackSendFailed(channel string) {
+	slackSendFailures.WithLabelValues(channel).Inc()
+}
+
+// ObserveRequest records how long the request to the route took. The route is the pattern matched, such as /teams/:team/members
+func ObserveRequest(route, method string, code int, duration time.Duration) {
+	requestDuration.WithLabelValues(route, method, strconv.Itoa(code)).Observe(duration.Seconds())
 }
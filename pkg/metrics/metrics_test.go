@@ -1,35 +1,132 @@
 package metrics_test
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"time"
+
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/supreethrao/automated-rota-manager/pkg/metrics"
 )
 
+// fakeTeam reports the days accrued and roles unconfirmed it is given
+type fakeTeam struct {
+	name        string
+	accrued     map[string]map[string]float64
+	unconfirmed map[string]bool
+	err         error
+}
+
+func (f *fakeTeam) Name() string {
+	return f.name
+}
+
+func (f *fakeTeam) AccruedByRole() (map[string]map[string]float64, error) {
+	return f.accrued, f.err
+}
+
+func (f *fakeTeam) UnconfirmedToday() (map[string]bool, error) {
+	return f.unconfirmed, f.err
+}
+
+// scrape returns the metrics as served on /metrics
+func scrape() string {
+	recorder := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(recorder.Body)
+	Expect(err).NotTo(HaveOccurred())
+	return string(body)
+}
+
 var _ = Describe("Tests for metrics", func() {
-	Context("Obtaining prometheus metrics", func() {
-		It("All team member should have the counter initialised", func() {
+	Context("Counting rota activity", func() {
+		It("Counts suggestions and confirmations", func() {
+			metrics.PickSuggested("suggest-team", "primary")
+			metrics.PickSuggested("suggest-team", "primary")
+			Expect(scrape()).To(ContainSubstring(`rota_picks_suggested_total{role="primary",team="suggest-team"} 2`))
+
+			metrics.Confirmed("suggest-team", "primary")
+			Expect(scrape()).To(ContainSubstring(`rota_confirms_total{role="primary",team="suggest-team"} 1`))
+		})
+
+		It("Counts overrides and cancellations", func() {
+			metrics.Overridden("change-team", "secondary")
+			Expect(scrape()).To(ContainSubstring(`rota_overrides_total{role="secondary",team="change-team"} 1`))
 
+			metrics.Cancelled("change-team", "secondary")
+			Expect(scrape()).To(ContainSubstring(`rota_cancellations_total{role="secondary",team="change-team"} 1`))
 		})
 
-		It("New member should have the counter initialised", func() {
+		It("Counts cron runs by outcome and Slack failures by channel", func() {
+			metrics.CronRun("cron-team", metrics.CronPicked)
+			metrics.CronRun("cron-team", metrics.CronPicked)
+			metrics.CronRun("cron-team", metrics.CronHoliday)
+			metrics.SlackSendFailed("#cron-team")
 
+			metricsText := scrape()
+			Expect(metricsText).To(ContainSubstring(`rota_cron_runs_total{outcome="picked",team="cron-team"} 2`))
+			Expect(metricsText).To(ContainSubstring(`rota_cron_runs_total{outcome="holiday",team="cron-team"} 1`))
+			Expect(metricsText).NotTo(ContainSubstring(`rota_cron_runs_total{outcome="failed",team="cron-team"}`))
+			Expect(metricsText).To(ContainSubstring(`rota_slack_send_failures_total{channel="#cron-team"} 1`))
 		})
+	})
+
+	Context("Reporting from the teams' databases", func() {
+		It("Reads the days accrued by the members of the watched teams on every scrape", func() {
+			team := &fakeTeam{name: "accrued-team", accrued: map[string]map[string]float64{
+				"primary": {"alice": 3, "bob": 1.5},
+			}}
+			metrics.Watch(team)
 
-		It("Get counter value based on member name", func() {
+			metricsText := scrape()
+			Expect(metricsText).To(ContainSubstring(`rota_member_accrued_days{member="alice",role="primary",team="accrued-team"} 3`))
+			Expect(metricsText).To(ContainSubstring(`rota_member_accrued_days{member="bob",role="primary",team="accrued-team"} 1.5`))
 
+			// Members who leave the team drop out rather than keep their last value
+			team.accrued = map[string]map[string]float64{"primary": {"alice": 4}}
+			metricsText = scrape()
+			Expect(metricsText).To(ContainSubstring(`rota_member_accrued_days{member="alice",role="primary",team="accrued-team"} 4`))
+			Expect(metricsText).NotTo(ContainSubstring(`member="bob"`))
 		})
 
-		It("Get member name based on date", func() {
+		It("Reads whether each role is unconfirmed today on every scrape", func() {
+			team := &fakeTeam{name: "unconfirmed-team", unconfirmed: map[string]bool{"primary": true, "secondary": false}}
+			metrics.Watch(team)
 
+			metricsText := scrape()
+			Expect(metricsText).To(ContainSubstring(`rota_unconfirmed_today{role="primary",team="unconfirmed-team"} 1`))
+			Expect(metricsText).To(ContainSubstring(`rota_unconfirmed_today{role="secondary",team="unconfirmed-team"} 0`))
+
+			// A new day starts out unconfirmed without anything resetting the value
+			team.unconfirmed = map[string]bool{"primary": false, "secondary": true}
+			metricsText = scrape()
+			Expect(metricsText).To(ContainSubstring(`rota_unconfirmed_today{role="primary",team="unconfirmed-team"} 0`))
+			Expect(metricsText).To(ContainSubstring(`rota_unconfirmed_today{role="secondary",team="unconfirmed-team"} 1`))
 		})
-	})
 
-	Context("Update prometheus metrics", func() {
-		It("Updates member name for the date", func() {
+		It("Leaves out teams whose history cannot be read", func() {
+			metrics.Watch(&fakeTeam{name: "broken-team", err: fmt.Errorf("db closed")})
+			metrics.Watch(&fakeTeam{name: "working-team", accrued: map[string]map[string]float64{"primary": {"carol": 2}}})
 
+			metricsText := scrape()
+			Expect(metricsText).NotTo(ContainSubstring(`team="broken-team"`))
+			Expect(metricsText).To(ContainSubstring(`rota_member_accrued_days{member="carol",role="primary",team="working-team"} 2`))
 		})
+	})
 
-		It("Updates counter with member name", func() {
+	Context("Timing HTTP requests", func() {
+		It("Records the duration by route rather than by the path requested", func() {
+			metrics.ObserveRequest("/teams/:team/timed", "GET", 200, 20*time.Millisecond)
+			metrics.ObserveRequest("/teams/:team/timed", "GET", 200, 2*time.Second)
+			metrics.ObserveRequest("/teams/:team/timed", "GET", 404, time.Millisecond)
 
+			metricsText := scrape()
+			Expect(metricsText).To(ContainSubstring(`rota_http_request_duration_seconds_count{code="200",method="GET",route="/teams/:team/timed"} 2`))
+			Expect(metricsText).To(ContainSubstring(`rota_http_request_duration_seconds_bucket{code="200",method="GET",route="/teams/:team/timed",le="0.025"} 1`))
+			Expect(metricsText).To(ContainSubstring(`rota_http_request_duration_seconds_count{code="404",method="GET",route="/teams/:team/timed"} 1`))
 		})
 	})
 })
@@ -0,0 +1,148 @@
+package rota
+
+import (
+	"math"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// assign adds the keys recording the member covering the role for every day of the shift starting on the given day.
+// It returns the ledger entry describing the assignment, for the caller to complete with who did it and why.
+func (t Team) assign(rotaKeys map[string][]byte, memberName, role string, start time.Time, days int) LedgerEntry {
+	assignment := LedgerEntry{
+		Member:            memberName,
+		Role:              role,
+		Date:              start.Format("02-01-2006"),
+		Days:              days,
+		Accrued:           t.accrualFor(start, days),
+		PreviousPickedDay: t.pendingLatestPickedDay(rotaKeys, memberName),
+	}
+
+	currentlyAccruedDays := t.pendingRead(rotaKeys, t.accruedDaysKey(memberName, role))
+	rotaKeys[t.accruedDaysKey(memberName, role)] = floatToBytes(bytesToFloat(currentlyAccruedDays) + assignment.Accrued)
+	if isNotBefore(assignment.Date, assignment.PreviousPickedDay) {
+		rotaKeys[t.LatestDayPickedKey(memberName)] = []byte(assignment.Date)
+	}
+	for _, day := range shiftDates(start, days) {
+		rotaKeys[t.pickedOnDayKey(day, role)] = []byte(memberName)
+	}
+
+	return assignment
+}
+
+// unassign adds the keys reverting the member's shift from the given day onwards and returns the number of days handed back.
+// When the whole shift is handed back, the day the member was picked before is restored as well.
+func (t Team) unassign(rotaKeys map[string][]byte, memberName, role string, from time.Time) (int, error) {
+	assignment, found, err := t.assignmentCovering(memberName, role, from)
+	if err != nil {
+		return 0, err
+	}
+
+	accruedDaysKey := t.accruedDaysKey(memberName, role)
+	currentlyAccruedDays := bytesToFloat(t.pendingRead(rotaKeys, accruedDaysKey))
+
+	if !found {
+		// Assignments made before the ledger existed were always for a single day
+		logrus.Warnf("no history of %s being assigned as %s on %s. Leaving their latest picked day as is", memberName, role, from.Format("02-01-2006"))
+		rotaKeys[accruedDaysKey] = floatToBytes(math.Max(currentlyAccruedDays-1, 0))
+		return 1, nil
+	}
+
+	start, err := time.Parse("02-01-2006", assignment.Date)
+	if err != nil {
+		return 0, err
+	}
+	from, _ = time.Parse("02-01-2006", from.Format("02-01-2006"))
+	remainingDays := assignment.shiftDays() - int(math.Round(from.Sub(start).Hours()/24))
+
+	if remainingDays >= assignment.shiftDays() {
+		rotaKeys[accruedDaysKey] = floatToBytes(math.Max(currentlyAccruedDays-assignment.accrued(), 0))
+		// A member picked again since keeps the later day
+		if t.pendingLatestPickedDay(rotaKeys, memberName) == assignment.Date {
+			if assignment.PreviousPickedDay == "N/A" {
+				// Marked for removal, as members never picked have no latest picked day
+				rotaKeys[t.LatestDayPickedKey(memberName)] = nil
+			} else {
+				rotaKeys[t.LatestDayPickedKey(memberName)] = []byte(assignment.PreviousPickedDay)
+			}
+		}
+	} else {
+		rotaKeys[accruedDaysKey] = floatToBytes(math.Max(currentlyAccruedDays-t.accrualFor(from, remainingDays), 0))
+	}
+	return remainingDays, nil
+}
+
+// writeAssignments writes the keys adjusted by assign and unassign in a single transaction, removing the keys marked for removal with a nil value
+func (t Team) writeAssignments(rotaKeys map[string][]byte, removals []string) error {
+	for key, value := range rotaKeys {
+		if value == nil {
+			removals = append(removals, key)
+			delete(rotaKeys, key)
+		}
+	}
+	return t.db.MultiWriteAndRemove(rotaKeys, removals)
+}
+
+// accrualFor is the cost of the days in the shift
+func (t Team) accrualFor(start time.Time, days int) float64 {
+	accrual := 0.0
+	for _, day := range shiftDates(start, days) {
+		accrual += t.dayAccrual(day)
+	}
+	return accrual
+}
+
+// dayAccrual is what covering the day accrues. Teams picking someone for a single day accrue the cost of the day whatever it is,
+// while longer shifts leave out holidays unless the cost table names them
+func (t Team) dayAccrual(day time.Time) float64 {
+	cost, named := t.costs.of(day, t.isHoliday)
+	if t.shiftDays <= 1 || named {
+		return cost
+	}
+	if isHoliday, _ := t.isHoliday(day); isHoliday {
+		return 0
+	}
+	return cost
+}
+
+// pendingRead prefers a value about to be written over the one stored, so that a member can be adjusted more than once in the same transaction
+func (t Team) pendingRead(rotaKeys map[string][]byte, key string) []byte {
+	if value, ok := rotaKeys[key]; ok {
+		return value
+	}
+	value, _ := t.db.Read(key)
+	return value
+}
+
+func (t Team) pendingLatestPickedDay(rotaKeys map[string][]byte, memberName string) string {
+	if value := t.pendingRead(rotaKeys, t.LatestDayPickedKey(memberName)); len(value) > 0 {
+		return string(value)
+	}
+	return "N/A"
+}
+
+// isNotBefore tells whether the day is the same as or after the other one. Never having been picked comes before any day
+func isNotBefore(day, other string) bool {
+	otherDay, err := time.Parse("02-01-2006", other)
+	if err != nil {
+		return true
+	}
+	thisDay, err := time.Parse("02-01-2006", day)
+	return err == nil && !thisDay.Before(otherDay)
+}
+
+func shiftDates(start time.Time, days int) []time.Time {
+	dates := []time.Time{start}
+	for day := 1; day < days; day++ {
+		dates = append(dates, start.AddDate(0, 0, day))
+	}
+	return dates
+}
+
+func isWeekend(day time.Time) (bool, string) {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return true, "Weekend"
+	}
+	return false, ""
+}
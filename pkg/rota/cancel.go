@@ -0,0 +1,83 @@
+package rota
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/supreethrao/automated-rota-manager/pkg/metrics"
+)
+
+func (t Team) CancelToday(actor string) (string, error) {
+	return t.CancelTodayForRole(t.roles[0], actor)
+}
+
+// CancelTodayForRole takes the member assigned to the role off the rota from today and reverts what their assignment accrued,
+// leaving the day free for a fresh pick. It returns who was taken off.
+// When the whole assignment is cancelled, the latest picked day and the latest cron run are restored to what they were before it.
+func (t Team) CancelTodayForRole(role string, actor string) (string, error) {
+	if err := t.checkRole(role); err != nil {
+		return "", err
+	}
+
+	assigned := t.PersonPickedOnTheDayForRole(t.now(), role)
+	if assigned == "UNKNOWN" {
+		return "", fmt.Errorf("nobody is assigned as %s today", role)
+	}
+
+	assignment, found, err := t.assignmentCovering(assigned, role, t.now())
+	if err != nil {
+		return "", err
+	}
+
+	rotaKeys := make(map[string][]byte)
+	remainingDays, err := t.unassign(rotaKeys, assigned, role, t.now())
+	if err != nil {
+		return "", err
+	}
+
+	removals := make([]string, 0, remainingDays)
+	for _, day := range shiftDates(t.now(), remainingDays) {
+		removals = append(removals, t.pickedOnDayKey(day, role))
+	}
+
+	// Assignments recorded before the latest cron run was kept in the ledger leave it as it is,
+	// as do those cancelled while another role is still covered today
+	if found && remainingDays >= assignment.shiftDays() && assignment.PreviousCronRun != "" && !t.otherRoleCoveredToday(role) {
+		if assignment.PreviousCronRun == "N/A" {
+			removals = append(removals, t.LatestCronRunKey())
+		} else {
+			rotaKeys[t.LatestCronRunKey()] = []byte(assignment.PreviousCronRun)
+		}
+	}
+
+	ledgerKey, ledgerEntry, err := t.ledgerEntry(LedgerEntry{Member: assigned, Role: role, Date: t.today(), Days: remainingDays, Action: ActionCancel, Actor: actor})
+	if err != nil {
+		return "", err
+	}
+	rotaKeys[ledgerKey] = ledgerEntry
+
+	log.Printf("Cancelling %s as %s from today and reverting their accrued days", assigned, role)
+	if err := t.writeAssignments(rotaKeys, removals); err != nil {
+		return "", err
+	}
+	t.record(metrics.Cancelled, role)
+	return assigned, nil
+}
+
+// otherRoleCoveredToday tells whether someone is still assigned today in a role other than the one given
+func (t Team) otherRoleCoveredToday(role string) bool {
+	for _, otherRole := range t.roles {
+		if otherRole != role && t.PersonPickedOnTheDayForRole(t.now(), otherRole) != "UNKNOWN" {
+			return true
+		}
+	}
+	return false
+}
+
+// latestCronRun is the day of the latest cron run, or N/A if there has not been one
+func (t Team) latestCronRun() string {
+	if lastRun, err := t.db.Read(t.LatestCronRunKey()); err == nil {
+		return string(lastRun)
+	}
+	return "N/A"
+}
@@ -0,0 +1,84 @@
+package rota
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultCost is what a day of cover accrues unless the cost table says otherwise
+const defaultCost = 1.0
+
+// Costs is how much a day of cover adds to the member's accrued days. The zero value costs every day the same.
+// A named date takes precedence over the day before a bank holiday, which takes precedence over the day of the week.
+type Costs struct {
+	weekdays   map[time.Weekday]float64
+	preHoliday *float64
+	dates      map[string]float64
+}
+
+// NewCosts reads a cost table keyed by day names such as monday, with named dates in the DD-MM-YYYY format.
+// A nil preHoliday leaves the day before a bank holiday costed as any other day.
+func NewCosts(weekdays map[string]float64, preHoliday *float64, dates map[string]float64) (Costs, error) {
+	costs := Costs{weekdays: make(map[time.Weekday]float64), preHoliday: preHoliday, dates: make(map[string]float64)}
+
+	for name, cost := range weekdays {
+		weekday, err := parseWeekday(name)
+		if err != nil {
+			return Costs{}, err
+		}
+		if cost < 0 {
+			return Costs{}, fmt.Errorf("cost of %s cannot be negative", name)
+		}
+		costs.weekdays[weekday] = cost
+	}
+
+	if preHoliday != nil && *preHoliday < 0 {
+		return Costs{}, fmt.Errorf("cost of the day before a bank holiday cannot be negative")
+	}
+
+	for date, cost := range dates {
+		day, err := time.Parse("02-01-2006", date)
+		if err != nil {
+			return Costs{}, fmt.Errorf("unable to parse date string %s - %v", date, err)
+		}
+		if cost < 0 {
+			return Costs{}, fmt.Errorf("cost of %s cannot be negative", date)
+		}
+		costs.dates[day.Format("02-01-2006")] = cost
+	}
+	return costs, nil
+}
+
+// of is the cost of covering the day, along with whether it was named in the cost table
+func (c Costs) of(day time.Time, isHoliday func(time.Time) (bool, string)) (float64, bool) {
+	if cost, ok := c.dates[day.Format("02-01-2006")]; ok {
+		return cost, true
+	}
+	if c.preHoliday != nil && isPreHoliday(day, isHoliday) {
+		return *c.preHoliday, false
+	}
+	if cost, ok := c.weekdays[day.Weekday()]; ok {
+		return cost, false
+	}
+	return defaultCost, false
+}
+
+// isPreHoliday tells whether the next weekday after the day is a bank holiday, such as the Friday before a bank holiday Monday
+func isPreHoliday(day time.Time, isHoliday func(time.Time) (bool, string)) bool {
+	next := day.AddDate(0, 0, 1)
+	for weekend, _ := isWeekend(next); weekend; weekend, _ = isWeekend(next) {
+		next = next.AddDate(0, 0, 1)
+	}
+	holiday, _ := isHoliday(next)
+	return holiday
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(weekday.String(), name) {
+			return weekday, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("%q is not a day of the week", name)
+}
@@ -0,0 +1,168 @@
+package rota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/supreethrao/automated-rota-manager/pkg/metrics"
+	"github.com/supreethrao/automated-rota-manager/pkg/slackhandler"
+)
+
+const (
+	AutoConfirm = "confirm"
+	AutoAdvance = "advance"
+)
+
+// PendingPick is a suggestion waiting to be confirmed by the deadline.
+// Passed lists the members suggested earlier in the day who let the deadline pass.
+type PendingPick struct {
+	Member   string
+	Role     string
+	Date     string
+	Deadline string
+	Passed   []string
+}
+
+// AwaitConfirmation starts the clock on the suggestions being confirmed. It does nothing for teams without a confirmation deadline
+func (t Team) AwaitConfirmation(selection Selection) error {
+	if t.confirmationDeadline <= 0 {
+		return nil
+	}
+
+	rotaKeys := make(map[string][]byte)
+	for ind, role := range selection.Roles {
+		if strings.HasPrefix(selection.Picks[ind], "UNKNOWN") {
+			continue
+		}
+		data, err := json.Marshal(t.pendingPick(selection.Picks[ind], role, nil))
+		if err != nil {
+			return err
+		}
+		rotaKeys[t.PendingPickKey(role)] = data
+	}
+	return t.db.MultiWrite(rotaKeys)
+}
+
+// PendingPicks lists the suggestions waiting to be confirmed
+func (t Team) PendingPicks() ([]PendingPick, error) {
+	pendingPicks := make([]PendingPick, 0)
+	for _, role := range t.roles {
+		data, err := t.db.Read(t.PendingPickKey(role))
+		if err != nil {
+			continue
+		}
+
+		var pending PendingPick
+		if err := json.Unmarshal(data, &pending); err != nil {
+			return nil, fmt.Errorf("unable to read the pick pending as %s: %v", role, err)
+		}
+		pendingPicks = append(pendingPicks, pending)
+	}
+	return pendingPicks, nil
+}
+
+// pendingPickFor is the suggestion made for the role today that is waiting to be confirmed, if there is one
+func (t Team) pendingPickFor(role string) (PendingPick, bool) {
+	var pending PendingPick
+	data, err := t.db.Read(t.PendingPickKey(role))
+	if err != nil || json.Unmarshal(data, &pending) != nil || pending.Date != t.today() {
+		return PendingPick{}, false
+	}
+	return pending, true
+}
+
+// CheckDeadlines acts on the suggestions whose deadline has passed and posts what was done
+func (t Team) CheckDeadlines(_ context.Context, slackMessager *slackhandler.Messager, ingressURL string) {
+	messages, err := t.ExpirePendingPicks(ingressURL)
+	if err != nil {
+		logrus.Errorf("unable to act on the confirmation deadline: %v", err)
+	}
+
+	for _, message := range messages {
+		if err := slackMessager.SendMessage(message); err != nil {
+			logrus.Errorf("unable to send slack message with error: %v", err)
+		}
+	}
+}
+
+// ExpirePendingPicks confirms the suggestions whose deadline has passed or moves on to the next eligible member, as configured.
+// Suggestions that have since been confirmed by hand, or were made on an earlier day, are dropped.
+// It returns the messages announcing what was done.
+func (t Team) ExpirePendingPicks(ingressURL string) ([]string, error) {
+	pendingPicks, err := t.PendingPicks()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]string, 0)
+	for _, pending := range pendingPicks {
+		deadline, err := time.Parse(time.RFC3339, pending.Deadline)
+		if err != nil {
+			return messages, fmt.Errorf("unable to parse the deadline %s - %v", pending.Deadline, err)
+		}
+
+		if pending.Date != t.today() || t.PersonPickedOnTheDayForRole(t.now(), pending.Role) != "UNKNOWN" {
+			if err := t.db.Remove(t.PendingPickKey(pending.Role)); err != nil {
+				return messages, err
+			}
+			continue
+		}
+		if t.now().Before(deadline) {
+			continue
+		}
+
+		message, err := t.expire(pending, ingressURL)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (t Team) expire(pending PendingPick, ingressURL string) (string, error) {
+	if t.onDeadline == AutoConfirm {
+		if err := t.SetPersonPickedForRole(pending.Role, pending.Member, "deadline"); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Nobody confirmed the %s pick in time, so %s is confirmed for today. \n", pending.Role, pending.Member),
+			t.db.Remove(t.PendingPickKey(pending.Role))
+	}
+
+	// Those picked for the other roles today cannot cover this one as well
+	passed := append(append([]string{}, pending.Passed...), pending.Member)
+	nextPerson, _, _, err := t.nextForRole(pending.Role, append(t.othersToday(pending.Role), passed...))
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(nextPerson, "UNKNOWN") {
+		return fmt.Sprintf("%s did not confirm in time and there is nobody else left to pick as %s today. \n", pending.Member, pending.Role),
+			t.db.Remove(t.PendingPickKey(pending.Role))
+	}
+
+	data, err := json.Marshal(t.pendingPick(nextPerson, pending.Role, passed))
+	if err != nil {
+		return "", err
+	}
+	if err := t.db.Write(t.PendingPickKey(pending.Role), data); err != nil {
+		return "", err
+	}
+	t.record(metrics.PickSuggested, pending.Role)
+	return fmt.Sprintf("%s did not confirm in time. The %s picked for today is now: %s. \n "+
+		"To confirm, all you have to do is to click: %s \n "+
+		"To decline, click: %s \n", pending.Member, pending.Role, t.mention(nextPerson), t.confirmURL(ingressURL, nextPerson, pending.Role), t.declineURL(ingressURL, nextPerson, pending.Role)), nil
+}
+
+func (t Team) pendingPick(member, role string, passed []string) PendingPick {
+	return PendingPick{
+		Member:   member,
+		Role:     role,
+		Date:     t.today(),
+		Deadline: t.now().Add(t.confirmationDeadline).Format(time.RFC3339),
+		Passed:   passed,
+	}
+}
@@ -0,0 +1,154 @@
+package rota
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/supreethrao/automated-rota-manager/pkg/metrics"
+)
+
+func (t Team) Decline(memberName, reason, actor string) (string, error) {
+	return t.DeclineForRole(t.roles[0], memberName, reason, actor)
+}
+
+// DeclineForRole records the member turning down today's suggestion for the role, along with their reason,
+// and counts it against them. They are not suggested for the role again today.
+// Only the member suggested today can decline.
+// It returns who is suggested instead, which is an UNKNOWN value when nobody else is left.
+func (t Team) DeclineForRole(role, memberName, reason, actor string) (string, error) {
+	if err := t.checkRole(role); err != nil {
+		return "", err
+	}
+
+	if assigned := t.PersonPickedOnTheDayForRole(t.now(), role); assigned != "UNKNOWN" {
+		return "", fmt.Errorf("%s is already confirmed as %s for the day. Override or cancel the assignment instead", assigned, role)
+	}
+
+	if err := t.checkMember(memberName); err != nil {
+		return "", err
+	}
+	suggested, err := t.suggestedToday(role)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(suggested, "UNKNOWN") {
+		return "", fmt.Errorf("nobody is suggested as %s today", role)
+	}
+	if suggested != memberName {
+		return "", fmt.Errorf("%s is the one suggested as %s today, so only they can decline", suggested, role)
+	}
+
+	declined := t.declinedToday(role)
+	if !contains(declined, memberName) {
+		declined = append(declined, memberName)
+	}
+	declinedData, err := json.Marshal(declined)
+	if err != nil {
+		return "", err
+	}
+
+	currentDeclines, _ := t.db.Read(t.DeclineCounterKey(memberName))
+	rotaKeys := map[string][]byte{
+		t.DeclinedKey(t.now(), role):    declinedData,
+		t.DeclineCounterKey(memberName): floatToBytes(bytesToFloat(currentDeclines) + 1),
+	}
+
+	ledgerKey, ledgerEntry, err := t.ledgerEntry(LedgerEntry{Member: memberName, Role: role, Date: t.today(), Action: ActionDecline, Actor: actor, Reason: reason})
+	if err != nil {
+		return "", err
+	}
+	rotaKeys[ledgerKey] = ledgerEntry
+
+	if err := t.db.MultiWrite(rotaKeys); err != nil {
+		return "", err
+	}
+
+	nextPerson, _, _, err := t.nextForRole(role, t.othersToday(role))
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(nextPerson, "UNKNOWN") {
+		t.record(metrics.PickSuggested, role)
+		if err := t.AwaitConfirmation(Selection{Roles: []string{role}, Picks: []string{nextPerson}}); err != nil {
+			return nextPerson, err
+		}
+	}
+	return nextPerson, nil
+}
+
+// DeclineMessage announces the decline along with who is suggested instead
+func (t Team) DeclineMessage(host, role, memberName, reason, nextPerson string) string {
+	message := fmt.Sprintf("%s declined being %s today", memberName, role)
+	if reason != "" {
+		message += fmt.Sprintf(" as %s", reason)
+	}
+	if strings.HasPrefix(nextPerson, "UNKNOWN") {
+		return message + fmt.Sprintf(". There is nobody else left to pick as %s today. \n", role)
+	}
+	return message + fmt.Sprintf(". The %s picked for today is now: %s. \n "+
+		"To confirm, click: %s \n "+
+		"To decline, click: %s \n", role, t.mention(nextPerson), t.confirmURL(host, nextPerson, role), t.declineURL(host, nextPerson, role))
+}
+
+// Declines counts how often each member has declined, most declines first
+func (t Team) Declines() (TeamRotaHistory, error) {
+	history, err := t.RotaHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Declines > history[j].Declines
+	})
+	return history, nil
+}
+
+// suggestedToday is who is waiting to be confirmed for the role today. Teams without a confirmation deadline keep nothing waiting,
+// so it is whoever the rota picks for the role, just as the scheduler suggested
+func (t Team) suggestedToday(role string) (string, error) {
+	if pending, waiting := t.pendingPickFor(role); waiting {
+		return pending.Member, nil
+	}
+
+	selection, err := t.Next()
+	if err != nil {
+		return "", err
+	}
+	for ind, selectedRole := range selection.Roles {
+		if selectedRole == role {
+			return selection.Picks[ind], nil
+		}
+	}
+	return "UNKNOWN", nil
+}
+
+// declinedToday lists the members who declined the role today
+func (t Team) declinedToday(role string) []string {
+	declined := make([]string, 0)
+	if data, err := t.db.Read(t.DeclinedKey(t.now(), role)); err == nil {
+		_ = json.Unmarshal(data, &declined)
+	}
+	return declined
+}
+
+// othersToday lists the members confirmed or waiting to be confirmed for the other roles today
+func (t Team) othersToday(role string) []string {
+	others := make([]string, 0)
+	for _, otherRole := range t.roles {
+		if otherRole == role {
+			continue
+		}
+		if assigned := t.PersonPickedOnTheDayForRole(t.now(), otherRole); assigned != "UNKNOWN" {
+			others = append(others, assigned)
+		}
+		if data, err := t.db.Read(t.PendingPickKey(otherRole)); err == nil {
+			var otherPending PendingPick
+			if json.Unmarshal(data, &otherPending) == nil {
+				others = append(others, otherPending.Member)
+			}
+		}
+	}
+	return others
+}
@@ -0,0 +1,80 @@
+package rota
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Explanation is how a member was considered for a role on the next pick.
+// DaysSincePicked is -1 for members never picked and Holiday names the holiday when the pick falls on one.
+type Explanation struct {
+	Member          string
+	Role            string
+	Load            float64
+	DaysSincePicked int
+	CoolingDown     bool
+	OutOfOffice     bool
+	Holiday         string
+	Decision        string
+}
+
+const (
+	DecisionPicked        = "picked"
+	DecisionAlreadyPicked = "already picked for the slot"
+	DecisionNotReached    = "not reached, someone ahead was picked"
+	DecisionCoolingDown   = "cooling down since their last pick"
+	DecisionLeftOut       = "left out by the strategy"
+)
+
+// Explain lists how every member was considered, one line each in the order they were considered
+func (s Selection) Explain() string {
+	var explanation strings.Builder
+	for _, considered := range s.Trace {
+		picked := "never picked"
+		if considered.DaysSincePicked >= 0 {
+			picked = fmt.Sprintf("picked %d days ago", considered.DaysSincePicked)
+		}
+		cooling, ooo, holiday := "", "", ""
+		if considered.CoolingDown {
+			cooling = ", cooling down"
+		}
+		if considered.OutOfOffice {
+			ooo = ", out of office"
+		}
+		if considered.Holiday != "" {
+			holiday = fmt.Sprintf(", pick falls on %s", considered.Holiday)
+		}
+		_, _ = fmt.Fprintf(&explanation, "%s as %s - %s. Load %g, %s%s%s%s \n", considered.Member, considered.Role, considered.Decision, considered.Load, picked, cooling, ooo, holiday)
+	}
+	return explanation.String()
+}
+
+// explain records how the member was considered, and logs it
+func (t Team) explain(individual IndividualHistory, role string, coolingDown map[string]bool, decision string) Explanation {
+	daysSincePicked := -1
+	if pickedDay, err := time.Parse("02-01-2006", individual.LatestPickedDay); err == nil {
+		today, _ := time.Parse("02-01-2006", t.today())
+		daysSincePicked = int(today.Sub(pickedDay).Hours() / 24)
+	}
+
+	holiday := ""
+	if isHoliday, name := t.isHoliday(t.now()); isHoliday {
+		holiday = name
+	}
+
+	explanation := Explanation{
+		Member:          individual.Name,
+		Role:            role,
+		Load:            individual.Load(),
+		DaysSincePicked: daysSincePicked,
+		CoolingDown:     coolingDown[individual.Name],
+		OutOfOffice:     !t.IsAvailable(individual.Name),
+		Holiday:         holiday,
+		Decision:        decision,
+	}
+	logrus.Debugf("considered %s as %s: %s", individual.Name, role, decision)
+	return explanation
+}
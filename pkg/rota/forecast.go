@@ -0,0 +1,99 @@
+package rota
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supreethrao/automated-rota-manager/pkg/localdb"
+)
+
+// maxForecastRuns stops a forecast from running forever on a cadence that hardly ever lands on a working day
+const maxForecastRuns = 1000
+
+// ForecastSlot is who is expected to be on the rota on the date.
+// Assigned is set when the slot has already been confirmed rather than projected.
+type ForecastSlot struct {
+	Date     string
+	Roles    []string
+	Picks    []string
+	Assigned bool
+}
+
+// Forecast projects the next count picks at the times given by nextRun, without writing anything to the team's database.
+// Every projected pick is confirmed on a copy of the team held in memory, so that it is taken into account by the picks after it.
+// Runs falling on a holiday are skipped, just like the scheduler does.
+func (t Team) Forecast(count int, nextRun func(time.Time) time.Time) ([]ForecastSlot, error) {
+	_, slots, err := t.project(nextRun, func(slots []ForecastSlot) bool {
+		return len(slots) == count
+	})
+	return slots, err
+}
+
+// project runs the rota forward on a copy of the team held in memory until done, returning the copy along with the slots projected
+func (t Team) project(nextRun func(time.Time) time.Time, done func([]ForecastSlot) bool) (Team, []ForecastSlot, error) {
+	run := t.now()
+	simulation := t
+	simulation.db = localdb.NewOverlay(t.db)
+	simulation.clock = func() time.Time {
+		return run
+	}
+
+	slots := make([]ForecastSlot, 0)
+	for runs := 0; !done(slots); runs++ {
+		if runs == maxForecastRuns {
+			return simulation, slots, fmt.Errorf("no working day found in the next %d runs", maxForecastRuns)
+		}
+
+		run = nextRun(run)
+		if isHoliday, _ := t.isHoliday(run); isHoliday {
+			continue
+		}
+
+		slot, err := simulation.forecastSlot(t)
+		if err != nil {
+			return simulation, slots, err
+		}
+		slots = append(slots, slot)
+	}
+	return simulation, slots, nil
+}
+
+// forecastSlot keeps whoever is already assigned for today and picks the next person for the remaining roles.
+// The slot is only assigned when every role is confirmed on the live team, rather than carried on from a multi day shift projected earlier.
+func (t Team) forecastSlot(live Team) (ForecastSlot, error) {
+	slot := ForecastSlot{Date: t.today(), Roles: t.Roles(), Picks: make([]string, len(t.roles)), Assigned: true}
+
+	covered := true
+	for ind, role := range t.roles {
+		slot.Picks[ind] = t.PersonPickedOnTheDayForRole(t.now(), role)
+		if slot.Picks[ind] == "UNKNOWN" {
+			covered = false
+		}
+		if live.PersonPickedOnTheDayForRole(t.now(), role) == "UNKNOWN" {
+			slot.Assigned = false
+		}
+	}
+	if covered {
+		return slot, nil
+	}
+
+	selection, err := t.Next()
+	if err != nil {
+		return slot, err
+	}
+
+	for ind, role := range t.roles {
+		if slot.Picks[ind] != "UNKNOWN" {
+			continue
+		}
+		slot.Picks[ind] = selection.Picks[ind]
+		if strings.HasPrefix(selection.Picks[ind], "UNKNOWN") {
+			continue
+		}
+		if err := t.SetPersonPickedForRole(role, selection.Picks[ind], "forecast"); err != nil {
+			return slot, err
+		}
+	}
+	return slot, nil
+}
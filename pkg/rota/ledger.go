@@ -0,0 +1,173 @@
+package rota
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	ActionPick     = "pick"
+	ActionConfirm  = "confirm"
+	ActionOverride = "override"
+	ActionCancel   = "cancel"
+	ActionSwap     = "swap"
+	ActionSkip     = "skip"
+	ActionRecord   = "record"
+	ActionDecline  = "decline"
+)
+
+// LedgerEntry is an immutable record of something that happened to the rota.
+// PreviousValue holds the member who was assigned for the date before this entry was recorded, if any.
+// PreviousPickedDay holds the day the member was last picked before this entry, so that it can be restored if they are displaced.
+// Assignments cover Days days starting on Date and Accrued is what they added to the member's accrued days.
+// Reason explains why a member was skipped or declined. PreviousCronRun holds the latest cron run before a confirmation, so that it can be restored if cancelled.
+type LedgerEntry struct {
+	Sequence          uint64
+	Member            string
+	Role              string
+	Date              string
+	Days              int
+	Accrued           float64
+	Action            string
+	Actor             string
+	PreviousValue     string
+	PreviousPickedDay string
+	Reason            string
+	PreviousCronRun   string
+	RecordedAt        string
+}
+
+// Ledger returns every entry recorded for the team, oldest first
+func (t Team) Ledger() ([]LedgerEntry, error) {
+	data, err := t.db.ReadWithPrefix(t.LedgerPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]LedgerEntry, 0, len(data))
+	for key, value := range data {
+		var entry LedgerEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return nil, fmt.Errorf("unable to read ledger entry %s: %v", key, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Sequence < entries[j].Sequence
+	})
+	return entries, nil
+}
+
+// LedgerOfIndividual returns the entries where the member was either assigned or displaced
+func (t Team) LedgerOfIndividual(member string) ([]LedgerEntry, error) {
+	entries, err := t.Ledger()
+	if err != nil {
+		return nil, err
+	}
+
+	memberEntries := make([]LedgerEntry, 0)
+	for _, entry := range entries {
+		if entry.Member == member || entry.PreviousValue == member {
+			memberEntries = append(memberEntries, entry)
+		}
+	}
+	return memberEntries, nil
+}
+
+// assignmentCovering looks up the entry that assigned the member to the role on the day.
+// The latest entry wins, so repeated overrides on the same day unwind correctly.
+// Assignments made before the ledger existed are not found and are reported as such.
+func (t Team) assignmentCovering(member, role string, day time.Time) (LedgerEntry, bool, error) {
+	entries, err := t.Ledger()
+	if err != nil {
+		return LedgerEntry{}, false, err
+	}
+
+	entry, found := t.assignmentIn(entries, member, role, day)
+	return entry, found, nil
+}
+
+// assignmentIn looks up the latest of the entries that assigned the member to the role on the day
+func (t Team) assignmentIn(entries []LedgerEntry, member, role string, day time.Time) (LedgerEntry, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Member == member && t.sameRole(entry.Role, role) && isAssignment(entry.Action) && entry.covers(day) {
+			return entry, true
+		}
+	}
+	return LedgerEntry{}, false
+}
+
+func (entry LedgerEntry) covers(day time.Time) bool {
+	for _, date := range entry.dates() {
+		if date == day.Format("02-01-2006") {
+			return true
+		}
+	}
+	return false
+}
+
+func (entry LedgerEntry) dates() []string {
+	start, err := time.Parse("02-01-2006", entry.Date)
+	if err != nil {
+		return []string{entry.Date}
+	}
+
+	dates := make([]string, 0, entry.shiftDays())
+	for _, date := range shiftDates(start, entry.shiftDays()) {
+		dates = append(dates, date.Format("02-01-2006"))
+	}
+	return dates
+}
+
+// Entries recorded before shifts were introduced cover a single day and accrued one day
+func (entry LedgerEntry) shiftDays() int {
+	if entry.Days == 0 {
+		return 1
+	}
+	return entry.Days
+}
+
+func (entry LedgerEntry) accrued() float64 {
+	if entry.Days == 0 {
+		return 1
+	}
+	return entry.Accrued
+}
+
+// sameRole also matches entries recorded before teams had roles to the first role
+func (t Team) sameRole(entryRole, role string) bool {
+	return entryRole == role || (entryRole == "" && t.isPrimary(role))
+}
+
+func isAssignment(action string) bool {
+	return action == ActionConfirm || action == ActionOverride || action == ActionSwap || action == ActionRecord
+}
+
+// ledgerEntry prepares an entry to be written along with the rest of the rota keys in a single transaction
+func (t Team) ledgerEntry(entry LedgerEntry) (string, []byte, error) {
+	sequence, err := t.db.NextSeq(t.LedgerSequenceKey())
+	if err != nil {
+		return "", nil, err
+	}
+
+	entry.Sequence = sequence
+	entry.RecordedAt = time.Now().Format(time.RFC3339)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", nil, err
+	}
+	return t.LedgerEntryKey(sequence), data, nil
+}
+
+func (t Team) recordLedgerEntry(entry LedgerEntry) error {
+	key, data, err := t.ledgerEntry(entry)
+	if err != nil {
+		return err
+	}
+	return t.db.Write(key, data)
+}
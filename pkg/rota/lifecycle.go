@@ -0,0 +1,152 @@
+package rota
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	StateActive   = "active"
+	StatePaused   = "paused"
+	StateArchived = "archived"
+)
+
+// memberState is kept for members who are not active
+type memberState struct {
+	State    string
+	ResumeOn string
+}
+
+// State is whether the member is active, paused or archived, along with the day a paused member resumes if one was set.
+// A paused member is active again from their resume day.
+func (t Team) State(memberName string) (string, string) {
+	data, err := t.db.Read(t.StateKey(memberName))
+	if err != nil {
+		return StateActive, ""
+	}
+
+	var state memberState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return StateActive, ""
+	}
+
+	if state.State == StatePaused && state.ResumeOn != "" {
+		resumeOn, err := time.Parse("02-01-2006", state.ResumeOn)
+		today, _ := time.Parse("02-01-2006", t.today())
+		if err == nil && !today.Before(resumeOn) {
+			return StateActive, ""
+		}
+	}
+	return state.State, state.ResumeOn
+}
+
+// Pause leaves the member out of the picks until they are resumed, or until the resume day when one is given.
+// They stay in the team and keep what they accrued.
+func (t Team) Pause(memberName string, resumeOn time.Time) error {
+	if err := t.checkMember(memberName); err != nil {
+		return err
+	}
+
+	state := memberState{State: StatePaused}
+	if !resumeOn.IsZero() {
+		state.ResumeOn = resumeOn.Format("02-01-2006")
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return t.db.Write(t.StateKey(memberName), data)
+}
+
+// Resume makes a paused member eligible for the picks again
+func (t Team) Resume(memberName string) error {
+	if err := t.checkMember(memberName); err != nil {
+		return err
+	}
+	return t.db.Remove(t.StateKey(memberName))
+}
+
+// Archive takes the member out of the team while keeping their accrued days, latest picked day and ledger.
+// Their out of office is cleared. When they are added back, their accrued days are lifted to what a new member would be seeded with
+// if they are lower, so that coming back does not leave them owing the shifts covered while they were away.
+func (t Team) Archive(memberName string) error {
+	currentMembers, err := t.List()
+	if err != nil {
+		return err
+	}
+	if !contains(currentMembers, memberName) {
+		return nil
+	}
+
+	updatedMembers := make([]string, 0, len(currentMembers)-1)
+	for _, member := range currentMembers {
+		if member != memberName {
+			updatedMembers = append(updatedMembers, member)
+		}
+	}
+
+	teamData, err := yaml.Marshal(teamMembers{updatedMembers})
+	if err != nil {
+		return err
+	}
+	stateData, err := json.Marshal(memberState{State: StateArchived})
+	if err != nil {
+		return err
+	}
+
+	oooFrom, oooTo := t.OutOfOfficeKey(memberName)
+	log.Printf("Archiving %s", memberName)
+	return t.db.MultiWriteAndRemove(map[string][]byte{
+		t.TeamKey():            teamData,
+		t.StateKey(memberName): stateData,
+	}, []string{oooFrom, oooTo, t.GraceKey(memberName)})
+}
+
+// Archived lists the members who have been archived
+func (t Team) Archived() ([]string, error) {
+	states, err := t.db.ReadWithPrefix(t.StatePrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	archived := make([]string, 0)
+	for key, data := range states {
+		var state memberState
+		if json.Unmarshal(data, &state) == nil && state.State == StateArchived {
+			archived = append(archived, strings.TrimPrefix(key, t.StatePrefix()))
+		}
+	}
+	sort.Strings(archived)
+	return archived, nil
+}
+
+// liftReturningMember raises an archived member's accrued days in the role to the seed when they are lower.
+// Their seed is raised by the same amount, so that their accrued days still reconcile with the days they covered.
+func (t Team) liftReturningMember(rotaKeys map[string][]byte, memberName, role string, seed float64) {
+	stored := t.HistoryOfIndividualForRole(memberName, role).DaysAccrued
+	if stored >= seed {
+		log.Printf("%s is back with their %g accrued days as %s", memberName, stored, role)
+		return
+	}
+
+	log.Printf("%s is back, lifting their accrued days as %s from %g to %g", memberName, role, stored, seed)
+	rotaKeys[t.accruedDaysKey(memberName, role)] = floatToBytes(seed)
+	rotaKeys[t.seedKey(memberName, role)] = floatToBytes(t.seed(memberName, role) + seed - stored)
+}
+
+func (t Team) checkMember(memberName string) error {
+	members, err := t.List()
+	if err != nil {
+		return err
+	}
+	if !contains(members, memberName) {
+		return fmt.Errorf("%s is not a member of the team", memberName)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package rota
+
+import (
+	"github.com/dgraph-io/badger"
+	"github.com/supreethrao/automated-rota-manager/pkg/metrics"
+)
+
+// AccruedByRole is the days accrued by every member, by role and then member
+func (t Team) AccruedByRole() (map[string]map[string]float64, error) {
+	accrued := make(map[string]map[string]float64, len(t.roles))
+	for _, role := range t.roles {
+		history, err := t.RotaHistoryForRole(role)
+		if err != nil {
+			return nil, err
+		}
+		accrued[role] = make(map[string]float64, len(history))
+		for _, individual := range history {
+			accrued[role][individual.Name] = individual.DaysAccrued
+		}
+	}
+	return accrued, nil
+}
+
+// UnconfirmedToday tells for every role whether nobody is confirmed for it today. Nothing is left unconfirmed on a holiday
+func (t Team) UnconfirmedToday() (map[string]bool, error) {
+	isHoliday, _ := t.isHoliday(t.now())
+
+	unconfirmed := make(map[string]bool, len(t.roles))
+	for _, role := range t.roles {
+		_, err := t.db.Read(t.pickedOnDayKey(t.now(), role))
+		if err != nil && err != badger.ErrKeyNotFound {
+			return nil, err
+		}
+		unconfirmed[role] = err != nil && !isHoliday
+	}
+	return unconfirmed, nil
+}
+
+// record reports the activity on the role to the metrics. Forecasts and simulations run on a clock of their own and are left out
+func (t Team) record(report func(team, role string), role string) {
+	if t.clock == nil {
+		report(t.name, role)
+	}
+}
+
+// recordCronRun reports the outcome of the scheduled pick to the metrics
+func (t Team) recordCronRun(outcome string) {
+	if t.clock == nil {
+		metrics.CronRun(t.name, outcome)
+	}
+}
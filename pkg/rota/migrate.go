@@ -0,0 +1,198 @@
+package rota
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Migration is a member moved from the name they were keyed by to their ID
+type Migration struct {
+	Name string
+	ID   string
+}
+
+// MigrateToIDs moves the members still keyed by the name they were added with over to their ID, and gives every member
+// without a profile one with that name as the display name. Their counters, days covered, pending picks, declines,
+// swap requests and ledger entries are all rewritten in a single write when apply is set.
+// Members already keyed by their ID only have their profile created, so running it again changes nothing.
+func (t Team) MigrateToIDs(apply bool) ([]Migration, error) {
+	members, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+	archived, err := t.Archived()
+	if err != nil {
+		return nil, err
+	}
+
+	names := append(append([]string{}, members...), archived...)
+	renames := make(map[string]string)
+	ids := make(map[string]string)
+	for _, name := range names {
+		id := MemberID(name)
+		if id == "" {
+			return nil, fmt.Errorf("%q does not make a valid member ID", name)
+		}
+		if other, taken := ids[id]; taken {
+			return nil, fmt.Errorf("%s and %s would both have the ID %s. Rename one of them first", other, name, id)
+		}
+		ids[id] = name
+		if id != name {
+			renames[name] = id
+		}
+	}
+
+	migrations := make([]Migration, 0, len(renames))
+	for name, id := range renames {
+		migrations = append(migrations, Migration{name, id})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Name < migrations[j].Name
+	})
+	if !apply {
+		return migrations, nil
+	}
+
+	rotaKeys := make(map[string][]byte)
+	removals := make([]string, 0)
+
+	for id, name := range ids {
+		if _, err := t.db.Read(t.ProfileKey(id)); err == nil {
+			continue
+		}
+		profile, err := json.Marshal(Profile{ID: id, DisplayName: name})
+		if err != nil {
+			return nil, err
+		}
+		rotaKeys[t.ProfileKey(id)] = profile
+	}
+
+	for name, id := range renames {
+		oldKeys, newKeys := t.memberKeys(name), t.memberKeys(id)
+		for ind, oldKey := range oldKeys {
+			if data, err := t.db.Read(oldKey); err == nil {
+				rotaKeys[newKeys[ind]] = data
+				removals = append(removals, oldKey)
+			}
+		}
+	}
+
+	if err := t.migrateValues(rotaKeys, renames); err != nil {
+		return nil, err
+	}
+
+	for ind, member := range members {
+		if id, renamed := renames[member]; renamed {
+			members[ind] = id
+		}
+	}
+	teamData, err := yaml.Marshal(teamMembers{members})
+	if err != nil {
+		return nil, err
+	}
+	rotaKeys[t.TeamKey()] = teamData
+
+	return migrations, t.db.MultiWriteAndRemove(rotaKeys, removals)
+}
+
+// memberKeys are the keys made from the member's ID, in the same order for every member
+func (t Team) memberKeys(member string) []string {
+	oooFrom, oooTo := t.OutOfOfficeKey(member)
+	memberKeys := []string{
+		t.WeightKey(member),
+		t.TagsKey(member),
+		t.SeedKey(member),
+		t.GraceKey(member),
+		t.StateKey(member),
+		t.LatestDayPickedKey(member),
+		t.DeclineCounterKey(member),
+		oooFrom,
+		oooTo,
+	}
+	for _, role := range t.roles {
+		memberKeys = append(memberKeys, t.accruedDaysKey(member, role))
+		if !t.isPrimary(role) {
+			memberKeys = append(memberKeys, t.seedKey(member, role))
+		}
+	}
+	return memberKeys
+}
+
+// migrateValues rewrites the values that refer to members by their name
+func (t Team) migrateValues(rotaKeys map[string][]byte, renames map[string]string) error {
+	rename := func(member string) string {
+		if id, renamed := renames[member]; renamed {
+			return id
+		}
+		return member
+	}
+
+	pendingKeys := make(map[string]bool)
+	for _, role := range t.roles {
+		pendingKeys[t.PendingPickKey(role)] = true
+	}
+
+	data, err := t.db.ReadWithPrefix(t.DayKeysPrefix())
+	if err != nil {
+		return err
+	}
+
+	for key, value := range data {
+		var migrated interface{}
+		switch {
+		case strings.HasPrefix(key, t.LedgerPrefix()):
+			var entry LedgerEntry
+			if json.Unmarshal(value, &entry) != nil {
+				continue
+			}
+			entry.Member, entry.PreviousValue = rename(entry.Member), rename(entry.PreviousValue)
+			migrated = entry
+		case strings.HasPrefix(key, t.SwapRequestPrefix()):
+			var swapRequest SwapRequest
+			if json.Unmarshal(value, &swapRequest) != nil {
+				continue
+			}
+			swapRequest.From, swapRequest.To = rename(swapRequest.From), rename(swapRequest.To)
+			migrated = swapRequest
+		case strings.HasPrefix(key, t.DeclinedPrefix()):
+			var declined []string
+			if json.Unmarshal(value, &declined) != nil {
+				continue
+			}
+			for ind, member := range declined {
+				declined[ind] = rename(member)
+			}
+			migrated = declined
+		case pendingKeys[key]:
+			var pending PendingPick
+			if json.Unmarshal(value, &pending) != nil {
+				continue
+			}
+			pending.Member = rename(pending.Member)
+			for ind, member := range pending.Passed {
+				pending.Passed[ind] = rename(member)
+			}
+			migrated = pending
+		default:
+			if _, _, isDayKey := t.ParsePersonPickedOnDayKey(key); isDayKey {
+				if id, renamed := renames[string(value)]; renamed {
+					rotaKeys[key] = []byte(id)
+				}
+			}
+			continue
+		}
+
+		migratedValue, err := json.Marshal(migrated)
+		if err != nil {
+			return err
+		}
+		if string(migratedValue) != string(value) {
+			rotaKeys[key] = migratedValue
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package rota
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	SeedMinimum = "minimum"
+	SeedMean    = "mean"
+	SeedMedian  = "median"
+	SeedZero    = "zero"
+)
+
+// Onboarding is how new members join the rota.
+// Seed is what their accrued days start at, relative to the rest of the team: SeedMinimum (default), SeedMean, SeedMedian or SeedZero.
+// GraceCycles is the number of slots filled by others after they join before they can be picked themselves.
+type Onboarding struct {
+	Seed        string
+	GraceCycles int
+}
+
+// grace is the ramp up period a member was given when they joined
+type grace struct {
+	Joined       string
+	Cycles       int
+	EligibleFrom string
+}
+
+func CheckOnboarding(onboarding Onboarding) error {
+	switch onboarding.Seed {
+	case "", SeedMinimum, SeedMean, SeedMedian, SeedZero:
+	default:
+		return fmt.Errorf("unknown onboarding seed %q", onboarding.Seed)
+	}
+	if onboarding.GraceCycles < 0 {
+		return fmt.Errorf("onboarding grace cycles cannot be negative")
+	}
+	return nil
+}
+
+// AddEligibleFrom adds the member to the rota, leaving them out of the picks until the date as well as for the team's grace cycles.
+// A zero date only applies the grace cycles.
+func (t Team) AddEligibleFrom(newMember string, eligibleFrom time.Time) error {
+	if err := t.Add(newMember); err != nil {
+		return err
+	}
+	if eligibleFrom.IsZero() {
+		return nil
+	}
+
+	newMember = t.idOf(newMember)
+	memberGrace := t.graceOf(newMember)
+	memberGrace.EligibleFrom = eligibleFrom.Format("02-01-2006")
+	data, err := json.Marshal(memberGrace)
+	if err != nil {
+		return err
+	}
+	return t.db.Write(t.GraceKey(newMember), data)
+}
+
+// onboardingSeed is what a new member's accrued days in the role start at
+func (t Team) onboardingSeed(role string) float64 {
+	if t.onboarding.Seed == SeedZero {
+		return 0
+	}
+
+	history, err := t.RotaHistoryForRole(role)
+	if err != nil || len(history) == 0 {
+		// This conditional required while adding the very first team member on a new deployment
+		return 1
+	}
+
+	loads := make([]float64, 0, len(history))
+	for _, individualHistory := range history {
+		loads = append(loads, individualHistory.Load())
+	}
+	sort.Float64s(loads)
+
+	switch t.onboarding.Seed {
+	case SeedMean:
+		total := 0.0
+		for _, load := range loads {
+			total += load
+		}
+		return total / float64(len(loads))
+	case SeedMedian:
+		middle := len(loads) / 2
+		if len(loads)%2 == 0 {
+			return (loads[middle-1] + loads[middle]) / 2
+		}
+		return loads[middle]
+	default:
+		return loads[0]
+	}
+}
+
+// newMemberGrace is the grace given to a member joining today
+func (t Team) newMemberGrace() ([]byte, error) {
+	return json.Marshal(grace{Joined: t.today(), Cycles: t.onboarding.GraceCycles})
+}
+
+func (t Team) graceOf(memberName string) grace {
+	var memberGrace grace
+	if data, err := t.db.Read(t.GraceKey(memberName)); err == nil {
+		_ = json.Unmarshal(data, &memberGrace)
+	}
+	return memberGrace
+}
+
+// inGrace describes what is left of the member's grace, or is empty once they are eligible
+func (t Team) inGrace(memberName string) string {
+	memberGrace := t.graceOf(memberName)
+	today, _ := time.Parse("02-01-2006", t.today())
+
+	if eligibleFrom, err := time.Parse("02-01-2006", memberGrace.EligibleFrom); err == nil && today.Before(eligibleFrom) {
+		return fmt.Sprintf("eligible from %s", memberGrace.EligibleFrom)
+	}
+
+	joined, err := time.Parse("02-01-2006", memberGrace.Joined)
+	if err != nil || memberGrace.Cycles == 0 {
+		return ""
+	}
+	if remaining := memberGrace.Cycles - t.slotsFilledBetween(joined, today, memberGrace.Cycles); remaining > 0 {
+		return fmt.Sprintf("%d cycles left", remaining)
+	}
+	return ""
+}
+
+// slotsFilledBetween counts the shifts someone covered the first role from the first day up to but not including the last,
+// stopping once the limit is reached. A shift covering several days is counted once
+func (t Team) slotsFilledBetween(first, last time.Time, limit int) int {
+	filled, shiftDay, previous := 0, 0, ""
+	for day := first; day.Before(last) && filled < limit; day = day.AddDate(0, 0, 1) {
+		covering, err := t.db.Read(t.PersonPickedOnDayKey(day))
+		if err != nil {
+			previous = ""
+			continue
+		}
+		if string(covering) != previous || shiftDay == t.shiftDays {
+			filled++
+			shiftDay = 0
+		}
+		shiftDay++
+		previous = string(covering)
+	}
+	return filled
+}
@@ -0,0 +1,43 @@
+package rota
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+)
+
+// ranksBefore tells whether the first member is considered for the pick ahead of the second. Members are ordered by
+//  1. their load, lowest first
+//  2. their latest picked day, earliest first, with those never picked ahead of everyone
+//  3. the day they joined, earliest first, with those who joined before join days were kept ahead of everyone
+//  4. a hash of their name with the seed, so that the remaining ties are settled the same way every time without favouring names early in the alphabet
+func ranksBefore(first, second IndividualHistory, seed string) bool {
+	if firstLoad, secondLoad := first.Load(), second.Load(); math.Abs(firstLoad-secondLoad) > 1e-9 {
+		return firstLoad < secondLoad
+	}
+
+	firstPicked, secondPicked := parseDay(latestPickedDay(first)), parseDay(latestPickedDay(second))
+	if !firstPicked.Equal(secondPicked) {
+		return firstPicked.Before(secondPicked)
+	}
+
+	firstJoined, secondJoined := parseDay(first.JoinedOn), parseDay(second.JoinedOn)
+	if !firstJoined.Equal(secondJoined) {
+		return firstJoined.Before(secondJoined)
+	}
+
+	return tieBreak(first.Name, seed) < tieBreak(second.Name, seed)
+}
+
+func tieBreak(name, seed string) uint64 {
+	hash := fnv.New64a()
+	_, _ = fmt.Fprintf(hash, "%s:%s", seed, name)
+	return hash.Sum64()
+}
+
+// parseDay reads a DD-MM-YYYY day, leaving days that can't be read as the zero time
+func parseDay(day string) time.Time {
+	parsed, _ := time.Parse("02-01-2006", day)
+	return parsed
+}
@@ -0,0 +1,157 @@
+package rota
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Profile is who a member is. The ID is fixed when they join and keys everything kept about them,
+// so the display name and the rest can be corrected without losing their history.
+type Profile struct {
+	ID          string
+	DisplayName string
+	SlackUserID string
+	Email       string
+	Timezone    string
+	Region      string
+}
+
+// MemberID is the ID a member joining with the name is given: the name in lower case with anything other than letters,
+// digits, dots, dashes and underscores replaced by a dash, such as third-person for "Third Person"
+func MemberID(name string) string {
+	var id strings.Builder
+	dash := false
+	for _, char := range strings.ToLower(strings.TrimSpace(name)) {
+		if (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') || char == '.' || char == '_' || char == '-' {
+			id.WriteRune(char)
+			dash = false
+		} else if !dash {
+			id.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.Trim(id.String(), "-")
+}
+
+// idOf is the ID of the member with the name, which is the name itself for members not yet migrated to IDs
+func (t Team) idOf(name string) string {
+	if members, err := t.List(); err == nil && contains(members, name) {
+		return name
+	}
+	return MemberID(name)
+}
+
+// Resolve is the ID of the current or archived member known by the name. The name can be their ID, the name they were added with,
+// or any name making the same ID, such as "Jane Doe" for jane-doe
+func (t Team) Resolve(name string) (string, error) {
+	members, err := t.List()
+	if err != nil {
+		return "", err
+	}
+	archived, err := t.Archived()
+	if err != nil {
+		return "", err
+	}
+
+	known := append(members, archived...)
+	if contains(known, name) {
+		return name, nil
+	}
+	for _, member := range known {
+		if id := MemberID(name); id != "" && MemberID(member) == id {
+			return member, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not a member of the team", name)
+}
+
+// Profile of the member. Members without one are shown by their ID.
+// Members not yet migrated to IDs are also given the profile saved under the ID they will move to
+func (t Team) Profile(id string) Profile {
+	profile := Profile{ID: id, DisplayName: id}
+	data, err := t.db.Read(t.ProfileKey(id))
+	if err != nil && MemberID(id) != id {
+		data, err = t.db.Read(t.ProfileKey(MemberID(id)))
+	}
+	if err == nil {
+		_ = json.Unmarshal(data, &profile)
+	}
+	return profile
+}
+
+// Profiles of the current members
+func (t Team) Profiles() ([]Profile, error) {
+	members, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]Profile, 0, len(members))
+	for _, member := range members {
+		profiles = append(profiles, t.Profile(member))
+	}
+	return profiles, nil
+}
+
+// SaveProfile adds the member with the profile, or updates the profile of an existing member.
+// The ID is taken from the display name when it is left out. The profile of a member not yet migrated is saved under the ID they will move to.
+func (t Team) SaveProfile(profile Profile) (Profile, error) {
+	if profile.ID == "" {
+		profile.ID = MemberID(profile.DisplayName)
+	}
+	if profile.DisplayName == "" {
+		profile.DisplayName = profile.ID
+	}
+	if err := checkProfile(profile); err != nil {
+		return profile, err
+	}
+
+	members, err := t.List()
+	if err != nil {
+		return profile, err
+	}
+	existing := false
+	for _, member := range members {
+		// Members not yet migrated are matched by the ID they will move to
+		if MemberID(member) == profile.ID {
+			existing = true
+		}
+	}
+	if !existing {
+		if err := t.Add(profile.ID); err != nil {
+			return profile, err
+		}
+	}
+
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return profile, err
+	}
+	return profile, t.db.Write(t.ProfileKey(profile.ID), data)
+}
+
+func checkProfile(profile Profile) error {
+	if profile.ID == "" || profile.ID != MemberID(profile.ID) {
+		return fmt.Errorf("invalid ID %q. Use lower case letters, digits, dots, dashes and underscores", profile.ID)
+	}
+	if profile.Email != "" && !strings.Contains(profile.Email, "@") {
+		return fmt.Errorf("invalid email %q", profile.Email)
+	}
+	if profile.Timezone != "" {
+		if _, err := time.LoadLocation(profile.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone %q: %v", profile.Timezone, err)
+		}
+	}
+	return nil
+}
+
+// mention is how the member is referred to on Slack, notifying them when their Slack user ID is known
+func (t Team) mention(id string) string {
+	profile := t.Profile(id)
+	if profile.SlackUserID != "" {
+		return fmt.Sprintf("<@%s>", profile.SlackUserID)
+	}
+	return profile.DisplayName
+}
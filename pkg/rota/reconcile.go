@@ -0,0 +1,194 @@
+package rota
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Discrepancy is a stored counter that disagrees with what the days the member covered add up to
+type Discrepancy struct {
+	Member  string
+	Role    string
+	Field   string
+	Stored  string
+	Derived string
+}
+
+// Reconcile rebuilds every member's accrued days and latest picked day from the days they are recorded as covering,
+// and lists those that differ from what is stored. When apply is set the derived values replace the stored ones in a single write.
+// Accrued days include the days a member was seeded with when they joined. Members who joined before seeds were kept and
+// accrued days before the ledger was kept have theirs taken as whatever their stored accrued days hold beyond the days they covered.
+// Those whose every day covered is in the ledger are seeded with nothing, so anything else they hold is listed. Both are written when apply is set.
+func (t Team) Reconcile(apply bool) ([]Discrepancy, error) {
+	members, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+
+	coveredDays, err := t.coveredDays()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := t.Ledger()
+	if err != nil {
+		return nil, err
+	}
+
+	discrepancies := make([]Discrepancy, 0)
+	rotaKeys := make(map[string][]byte)
+	removals := make([]string, 0)
+
+	for _, member := range members {
+		for _, role := range t.roles {
+			covered, recorded := t.accruedCovering(member, role, coveredDays[role][member], entries)
+			stored := t.HistoryOfIndividualForRole(member, role).DaysAccrued
+
+			seed, seeded := t.storedSeed(member, role)
+			if !seeded {
+				// Only what was accrued before the ledger was kept can be told apart from drift
+				if !recorded {
+					seed = math.Max(stored-covered, 0)
+				}
+				rotaKeys[t.seedKey(member, role)] = floatToBytes(seed)
+			}
+
+			if derived := seed + covered; math.Abs(stored-derived) > 1e-9 {
+				discrepancies = append(discrepancies, Discrepancy{member, role, "DaysAccrued", formatDays(stored), formatDays(derived)})
+				rotaKeys[t.accruedDaysKey(member, role)] = floatToBytes(derived)
+			}
+		}
+
+		stored := t.HistoryOfIndividual(member).LatestPickedDay
+		derived := t.latestShiftStart(member, coveredDays)
+		if stored != derived {
+			discrepancies = append(discrepancies, Discrepancy{member, "", "LatestPickedDay", stored, derived})
+			if derived == "N/A" {
+				removals = append(removals, t.LatestDayPickedKey(member))
+			} else {
+				rotaKeys[t.LatestDayPickedKey(member)] = []byte(derived)
+			}
+		}
+	}
+
+	if apply && (len(rotaKeys) > 0 || len(removals) > 0) {
+		return discrepancies, t.db.MultiWriteAndRemove(rotaKeys, removals)
+	}
+	return discrepancies, nil
+}
+
+// accruedCovering adds up what the ledger recorded the member's assignments in the role as accruing, for the days they still cover.
+// Shifts handed over part way through keep what they accrued less the cost of the days handed over,
+// and days covered before the ledger was kept accrued a day each. It also tells whether the member covered any days, all of them in the ledger.
+func (t Team) accruedCovering(member, role string, days []time.Time, entries []LedgerEntry) (float64, bool) {
+	covering := make(map[string]bool, len(days))
+	for _, day := range days {
+		covering[day.Format("02-01-2006")] = true
+	}
+
+	accrued := 0.0
+	recorded := len(days) > 0
+	counted := make(map[uint64]bool)
+	for _, day := range days {
+		assignment, found := t.assignmentIn(entries, member, role, day)
+		if !found {
+			accrued++
+			recorded = false
+			continue
+		}
+		if counted[assignment.Sequence] {
+			continue
+		}
+		counted[assignment.Sequence] = true
+
+		accrued += assignment.accrued()
+		for _, date := range assignment.dates() {
+			if handedOver, err := time.Parse("02-01-2006", date); err == nil && !covering[date] {
+				accrued -= t.dayAccrual(handedOver)
+			}
+		}
+	}
+	return accrued, recorded
+}
+
+// coveredDays lists the days each member covered in each role, oldest first
+func (t Team) coveredDays() (map[string]map[string][]time.Time, error) {
+	data, err := t.db.ReadWithPrefix(t.DayKeysPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	covered := make(map[string]map[string][]time.Time)
+	for _, role := range t.roles {
+		covered[role] = make(map[string][]time.Time)
+	}
+
+	for key, member := range data {
+		day, role, ok := t.ParsePersonPickedOnDayKey(key)
+		if !ok {
+			continue
+		}
+		if role == "" {
+			role = t.roles[0]
+		}
+		if _, configured := covered[role]; !configured {
+			continue
+		}
+		covered[role][string(member)] = append(covered[role][string(member)], day)
+	}
+
+	for _, members := range covered {
+		for _, days := range members {
+			sort.Slice(days, func(i, j int) bool {
+				return days[i].Before(days[j])
+			})
+		}
+	}
+	return covered, nil
+}
+
+// latestShiftStart is the first day of the latest shift the member covered in any role
+func (t Team) latestShiftStart(member string, coveredDays map[string]map[string][]time.Time) string {
+	var latest time.Time
+	for _, members := range coveredDays {
+		days := members[member]
+		if len(days) == 0 {
+			continue
+		}
+
+		// Walk back through the rest of the shift the last day belongs to
+		start := len(days) - 1
+		for start > 0 && len(days)-start < t.shiftDays && days[start-1].Equal(days[start].AddDate(0, 0, -1)) {
+			start--
+		}
+		if days[start].After(latest) {
+			latest = days[start]
+		}
+	}
+
+	if latest.IsZero() {
+		return "N/A"
+	}
+	return latest.Format("02-01-2006")
+}
+
+// seed is what the member joined with in the role
+func (t Team) seed(member, role string) float64 {
+	seed, _ := t.storedSeed(member, role)
+	return seed
+}
+
+// storedSeed is what the member joined with in the role, or false for members who joined before seeds were kept
+func (t Team) storedSeed(member, role string) (float64, bool) {
+	seed, err := t.db.Read(t.seedKey(member, role))
+	if err != nil {
+		return 0, false
+	}
+	return bytesToFloat(seed), true
+}
+
+func formatDays(days float64) string {
+	return fmt.Sprintf("%g", days)
+}
@@ -0,0 +1,73 @@
+package rota
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+func (t Team) RecordAssignment(memberName string, date time.Time, actor string) error {
+	return t.RecordAssignmentForRole(t.roles[0], memberName, date, actor)
+}
+
+// RecordAssignmentForRole records the member covering the role on a past date, such as when the bot was down or the day was covered informally.
+// Whoever was recorded on the date before is taken off from that date and both members' accrued days are recalculated.
+// The member's latest picked day only moves if the date is later than the one stored.
+func (t Team) RecordAssignmentForRole(role string, memberName string, date time.Time, actor string) error {
+	if err := t.checkRole(role); err != nil {
+		return err
+	}
+
+	day, err := time.Parse("02-01-2006", date.Format("02-01-2006"))
+	if err != nil {
+		return err
+	}
+	today, _ := time.Parse("02-01-2006", t.today())
+	if day.After(today) {
+		return fmt.Errorf("%s is in the future. Assignments can only be recorded for today or earlier", day.Format("02-01-2006"))
+	}
+
+	rotaKeys := make(map[string][]byte)
+	previouslyAssigned := t.PersonPickedOnTheDayForRole(day, role)
+
+	if previouslyAssigned == memberName {
+		log.Printf("%s is already recorded as %s on %s. Nothing to correct", memberName, role, day.Format("02-01-2006"))
+		return nil
+	}
+
+	var days int
+	if previouslyAssigned == "UNKNOWN" {
+		previouslyAssigned = ""
+		days = t.unassignedDaysFrom(day, role)
+	} else if days, err = t.unassign(rotaKeys, previouslyAssigned, role, day); err != nil {
+		return err
+	}
+
+	if err := t.checkNotCoveringAnotherRole(memberName, role, day, days); err != nil {
+		return err
+	}
+
+	assignment := t.assign(rotaKeys, memberName, role, day, days)
+	assignment.Action = ActionRecord
+	assignment.Actor = actor
+	assignment.PreviousValue = previouslyAssigned
+
+	ledgerKey, ledgerEntry, err := t.ledgerEntry(assignment)
+	if err != nil {
+		return err
+	}
+	rotaKeys[ledgerKey] = ledgerEntry
+
+	log.Printf("Recording %q as %s on %s", memberName, role, day.Format("02-01-2006"))
+	return t.writeAssignments(rotaKeys, nil)
+}
+
+// unassignedDaysFrom is how much of a shift starting on the day can be recorded before running into the next person's shift
+func (t Team) unassignedDaysFrom(day time.Time, role string) int {
+	for days, shiftDay := range shiftDates(day, t.shiftDays) {
+		if days > 0 && t.PersonPickedOnTheDayForRole(shiftDay, role) != "UNKNOWN" {
+			return days
+		}
+	}
+	return t.shiftDays
+}
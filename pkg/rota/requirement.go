@@ -0,0 +1,211 @@
+package rota
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Requirement is what a member's tags have to satisfy for them to be picked, such as `k8s-admin && (dba || !on-site)`
+type Requirement interface {
+	Satisfied(tags map[string]bool) bool
+	String() string
+}
+
+// ParseRequirement reads a requirement made of tags combined with &&, || and !, grouped with brackets.
+// An empty expression is satisfied by everyone and returns a nil requirement.
+func ParseRequirement(expression string) (Requirement, error) {
+	tokens, err := tokenise(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	parser := &requirementParser{tokens: tokens}
+	requirement, err := parser.or()
+	if err != nil {
+		return nil, fmt.Errorf("invalid requirement %q: %v", expression, err)
+	}
+	if parser.position < len(tokens) {
+		return nil, fmt.Errorf("invalid requirement %q: unexpected %q", expression, tokens[parser.position])
+	}
+	return requirement, nil
+}
+
+type tagRequirement string
+
+func (tag tagRequirement) Satisfied(tags map[string]bool) bool {
+	return tags[string(tag)]
+}
+
+func (tag tagRequirement) String() string {
+	return string(tag)
+}
+
+type notRequirement struct {
+	requirement Requirement
+}
+
+func (not notRequirement) Satisfied(tags map[string]bool) bool {
+	return !not.requirement.Satisfied(tags)
+}
+
+func (not notRequirement) String() string {
+	return "!" + not.requirement.String()
+}
+
+type allRequirement []Requirement
+
+func (all allRequirement) Satisfied(tags map[string]bool) bool {
+	for _, requirement := range all {
+		if !requirement.Satisfied(tags) {
+			return false
+		}
+	}
+	return true
+}
+
+func (all allRequirement) String() string {
+	return joinRequirements(all, " && ")
+}
+
+type anyRequirement []Requirement
+
+func (anyOf anyRequirement) Satisfied(tags map[string]bool) bool {
+	for _, requirement := range anyOf {
+		if requirement.Satisfied(tags) {
+			return true
+		}
+	}
+	return false
+}
+
+func (anyOf anyRequirement) String() string {
+	return joinRequirements(anyOf, " || ")
+}
+
+func joinRequirements(requirements []Requirement, operator string) string {
+	parts := make([]string, 0, len(requirements))
+	for _, requirement := range requirements {
+		parts = append(parts, requirement.String())
+	}
+	return "(" + strings.Join(parts, operator) + ")"
+}
+
+// requirementParser is a recursive descent parser where && binds tighter than ||
+type requirementParser struct {
+	tokens   []string
+	position int
+}
+
+func (p *requirementParser) or() (Requirement, error) {
+	requirements := anyRequirement{}
+	for {
+		requirement, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, requirement)
+		if !p.accept("||") {
+			break
+		}
+	}
+	if len(requirements) == 1 {
+		return requirements[0], nil
+	}
+	return requirements, nil
+}
+
+func (p *requirementParser) and() (Requirement, error) {
+	requirements := allRequirement{}
+	for {
+		requirement, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, requirement)
+		if !p.accept("&&") {
+			break
+		}
+	}
+	if len(requirements) == 1 {
+		return requirements[0], nil
+	}
+	return requirements, nil
+}
+
+func (p *requirementParser) unary() (Requirement, error) {
+	if p.position >= len(p.tokens) {
+		return nil, fmt.Errorf("expression ends early")
+	}
+
+	switch token := p.tokens[p.position]; token {
+	case "!":
+		p.position++
+		requirement, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notRequirement{requirement}, nil
+	case "(":
+		p.position++
+		requirement, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing closing bracket")
+		}
+		return requirement, nil
+	case ")", "&&", "||":
+		return nil, fmt.Errorf("unexpected %q", token)
+	default:
+		p.position++
+		return tagRequirement(token), nil
+	}
+}
+
+func (p *requirementParser) accept(token string) bool {
+	if p.position < len(p.tokens) && p.tokens[p.position] == token {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func tokenise(expression string) ([]string, error) {
+	tokens := make([]string, 0)
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]):
+			i++
+		case runes[i] == '!' || runes[i] == '(' || runes[i] == ')':
+			tokens = append(tokens, string(runes[i]))
+			i++
+		case runes[i] == '&' || runes[i] == '|':
+			if i+1 >= len(runes) || runes[i+1] != runes[i] {
+				return nil, fmt.Errorf("invalid requirement %q: use %c%c", expression, runes[i], runes[i])
+			}
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		case isTagRune(runes[i]):
+			start := i
+			for i < len(runes) && isTagRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			return nil, fmt.Errorf("invalid requirement %q: unexpected %q", expression, runes[i])
+		}
+	}
+	return tokens, nil
+}
+
+// isTagRune tells the characters allowed in a tag, such as in k8s-admin or on_site
+func isTagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.'
+}
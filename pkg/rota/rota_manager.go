@@ -4,17 +4,37 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/supreethrao/automated-rota-manager/pkg/metrics"
 	"github.com/supreethrao/automated-rota-manager/pkg/slackhandler"
 )
 
 type IndividualHistory struct {
 	Name            string
-	DaysAccrued     uint16
+	DaysAccrued     float64
 	LatestPickedDay string
+	Weight          float64
+	Declines        int
+	// State is whether the member is active, paused or archived. ResumeOn is the day a paused member is picked again, if set
+	State    string
+	ResumeOn string
+	// JoinedOn is the day the member was added, or empty for members added before join days were kept
+	JoinedOn string
+	// Grace is what is left of a new member's onboarding grace, or empty once they can be picked
+	Grace string
+}
+
+// Load is the accrued days normalised by the member's share of the rota, so that part timers are picked proportionally less
+func (individual IndividualHistory) Load() float64 {
+	if individual.Weight <= 0 {
+		return individual.DaysAccrued
+	}
+	return individual.DaysAccrued / individual.Weight
 }
 
 type TeamRotaHistory []IndividualHistory
@@ -27,29 +47,134 @@ func (history TeamRotaHistory) Swap(i, j int) {
 	history[i], history[j] = history[j], history[i]
 }
 
+// Less orders the members as they are considered for the pick, see ranksBefore. Ties left after the join date are settled by an unseeded hash
 func (history TeamRotaHistory) Less(i, j int) bool {
-	return history[i].DaysAccrued < history[j].DaysAccrued
+	return ranksBefore(history[i], history[j], "")
+}
+
+// Selection is who should cover the next slot, one person for each of the team's roles
+type Selection struct {
+	Roles   []string
+	Picks   []string
+	Skipped []Skip
+	// Trace is how every member was considered for each role
+	Trace []Explanation
+}
+
+// Skip records why a member was passed over for a role
+type Skip struct {
+	Member string
+	Role   string
+	Reason string
+}
+
+// Primary is the person picked for the first role
+func (s Selection) Primary() string {
+	return s.Picks[0]
 }
 
 func (t Team) OrderedRota() []IndividualHistory {
-	history, err := t.RotaHistory()
+	return t.OrderedRotaForRole(t.roles[0])
+}
+
+func (t Team) OrderedRotaForRole(role string) []IndividualHistory {
+	history, err := t.RotaHistoryForRole(role)
 	if err != nil {
 		logrus.Errorf("unable to obtain rota history: %v", err)
 		return nil
 	}
-	return orderedList(history)
+	return t.orderedList(history)
 }
 
 func (t Team) PickNextPerson(_ context.Context, slackMessager *slackhandler.Messager, ingressURL string) {
-	nextPersonOnRota, err := t.Next()
+	selection, err := t.Next()
 	if err != nil {
 		logrus.Errorf("picking next person errored with error: %v", err)
+		t.recordCronRun(metrics.CronFailed)
 		return
 	}
 
-	message := fmt.Sprintf("The person picked for today is: %s. \n "+
-		"To confirm, all you have to do is to click: %s/rota/confirm/%s/%s \n\n \n"+
-		"To select a different person, click the below ordered link: \n\n %s", nextPersonOnRota, ingressURL, nextPersonOnRota, time.Now().Format("02-01-2006"), t.orderedRotaMessage(ingressURL))
+	// Roles already covered today, such as by a swap accepted ahead of time or a later day of a shift, are announced rather than picked again
+	covered := make([]bool, len(selection.Roles))
+	for ind, role := range selection.Roles {
+		if assigned := t.PersonPickedOnTheDayForRole(t.now(), role); assigned != "UNKNOWN" {
+			selection.Picks[ind], covered[ind] = assigned, true
+		}
+	}
+
+	outcome := metrics.CronNobody
+	for ind, role := range selection.Roles {
+		if covered[ind] {
+			outcome = metrics.CronPicked
+			continue
+		}
+		if strings.HasPrefix(selection.Picks[ind], "UNKNOWN") {
+			continue
+		}
+		t.record(metrics.PickSuggested, role)
+		outcome = metrics.CronPicked
+	}
+	t.recordCronRun(outcome)
+
+	var message string
+	if len(selection.Roles) == 1 && covered[0] {
+		message = fmt.Sprintf("%s is already covering today. \n\n"+
+			"To select a different person, click the below ordered link: \n\n %s", t.mention(selection.Primary()), t.orderedRotaMessage(ingressURL, selection.Roles[0]))
+	} else if len(selection.Roles) == 1 && strings.HasPrefix(selection.Primary(), "UNKNOWN") {
+		message = fmt.Sprintf("Nobody could be picked for today. \n\n"+
+			"To select someone, click the below ordered link: \n\n %s", t.orderedRotaMessage(ingressURL, selection.Roles[0]))
+	} else if len(selection.Roles) == 1 {
+		message = fmt.Sprintf("The person picked for today is: %s. \n "+
+			"To confirm, all you have to do is to click: %s \n "+
+			"To decline, click: %s \n\n \n"+
+			"To select a different person, click the below ordered link: \n\n %s", t.mention(selection.Primary()), t.confirmURL(ingressURL, selection.Primary(), selection.Roles[0]), t.declineURL(ingressURL, selection.Primary(), selection.Roles[0]), t.orderedRotaMessage(ingressURL, selection.Roles[0]))
+	} else {
+		message = "The people picked for today are: \n"
+		for ind, role := range selection.Roles {
+			if covered[ind] {
+				message += fmt.Sprintf("%s: %s is already covering today \n", role, t.mention(selection.Picks[ind]))
+				continue
+			}
+			if strings.HasPrefix(selection.Picks[ind], "UNKNOWN") {
+				message += fmt.Sprintf("%s: nobody could be picked \n", role)
+				continue
+			}
+			message += fmt.Sprintf("%s: %s. To confirm, click: %s To decline, click: %s \n", role, t.mention(selection.Picks[ind]), t.confirmURL(ingressURL, selection.Picks[ind], role), t.declineURL(ingressURL, selection.Picks[ind], role))
+		}
+		for _, role := range selection.Roles {
+			message += fmt.Sprintf("\n To select a different %s, click the below ordered link: \n\n %s", role, t.orderedRotaMessage(ingressURL, role))
+		}
+	}
+
+	if t.explainPicks {
+		message += "\n\n How everyone was considered: \n" + selection.Explain()
+	}
+
+	for _, skip := range selection.Skipped {
+		message += fmt.Sprintf("\n Skipped %s as %s: %s", skip.Member, skip.Role, skip.Reason)
+		if err := t.recordLedgerEntry(LedgerEntry{Member: skip.Member, Role: skip.Role, Date: t.today(), Action: ActionSkip, Actor: "scheduler", Reason: skip.Reason}); err != nil {
+			logrus.Errorf("unable to record the skip in the ledger: %v", err)
+		}
+	}
+
+	// Only the roles picked for today wait to be confirmed
+	suggested := Selection{Roles: selection.Roles, Picks: append([]string{}, selection.Picks...)}
+	for ind, role := range selection.Roles {
+		if covered[ind] {
+			suggested.Picks[ind] = "UNKNOWN-COVERED"
+			continue
+		}
+		if strings.HasPrefix(selection.Picks[ind], "UNKNOWN") {
+			continue
+		}
+		if err := t.recordLedgerEntry(LedgerEntry{Member: selection.Picks[ind], Role: role, Date: t.today(), Action: ActionPick, Actor: "scheduler"}); err != nil {
+			logrus.Errorf("unable to record the pick in the ledger: %v", err)
+		}
+	}
+
+	if err := t.AwaitConfirmation(suggested); err != nil {
+		logrus.Errorf("unable to start the confirmation deadline: %v", err)
+	}
 
 	if err := slackMessager.SendMessage(message); err != nil {
 		logrus.Errorf("unable to send slack message with error: %v", err)
@@ -57,7 +182,11 @@ func (t Team) PickNextPerson(_ context.Context, slackMessager *slackhandler.Mess
 }
 
 func (t Team) PersonPickedOnTheDay(date time.Time) string {
-	personPicked, err := t.db.Read(t.PersonPickedOnDayKey(date))
+	return t.PersonPickedOnTheDayForRole(date, t.roles[0])
+}
+
+func (t Team) PersonPickedOnTheDayForRole(date time.Time, role string) string {
+	personPicked, err := t.db.Read(t.pickedOnDayKey(date, role))
 	if err != nil {
 		log.Printf("Unable to retrieve person picked on %v. error: %v", date, err)
 		return "UNKNOWN"
@@ -65,74 +194,199 @@ func (t Team) PersonPickedOnTheDay(date time.Time) string {
 	return string(personPicked)
 }
 
-func (t Team) SetPersonPickedForToday(memberName string) error {
+func (t Team) SetPersonPickedForToday(memberName string, actor string) error {
+	return t.SetPersonPickedForRole(t.roles[0], memberName, actor)
+}
+
+func (t Team) SetPersonPickedForRole(role string, memberName string, actor string) error {
+	if err := t.checkRole(role); err != nil {
+		return err
+	}
+
 	rotaKeys := make(map[string][]byte)
 
-	personAssignedForTheDay := t.PersonPickedOnTheDay(time.Now())
+	personAssignedForTheDay := t.PersonPickedOnTheDayForRole(t.now(), role)
 
 	if personAssignedForTheDay != "UNKNOWN" {
 		return fmt.Errorf("%s is already assigned for the day", personAssignedForTheDay)
 	}
 
-	currentlyAccruedDays, _ := t.db.Read(t.AccruedDaysCounterKey(memberName))
-	newAccruedDays := uintToBytes(bytesToUint(currentlyAccruedDays) + 1)
+	// A shift covering more than a day must not run into the next person's shift
+	for _, day := range shiftDates(t.now(), t.shiftDays)[1:] {
+		if assigned := t.PersonPickedOnTheDayForRole(day, role); assigned != "UNKNOWN" {
+			return fmt.Errorf("%s is already assigned on %s", assigned, day.Format("02-01-2006"))
+		}
+	}
 
-	rotaKeys[t.AccruedDaysCounterKey(memberName)] = newAccruedDays
-	rotaKeys[t.LatestDayPickedKey(memberName)] = []byte(today())
-	rotaKeys[t.PersonPickedOnDayKey(time.Now())] = []byte(memberName)
-	rotaKeys[t.LatestCronRunKey()] = []byte(today())
+	if err := t.checkNotCoveringAnotherRole(memberName, role, t.now(), t.shiftDays); err != nil {
+		return err
+	}
 
-	log.Printf("Confirming the selection for the day %q and updating the db with the new accrued number details", memberName)
-	err := t.db.MultiWrite(rotaKeys)
+	assignment := t.assign(rotaKeys, memberName, role, t.now(), t.shiftDays)
+	assignment.PreviousCronRun = t.latestCronRun()
+	rotaKeys[t.LatestCronRunKey()] = []byte(t.today())
+	assignment.Action = ActionConfirm
+	assignment.Actor = actor
+
+	ledgerKey, ledgerEntry, err := t.ledgerEntry(assignment)
+	if err != nil {
+		return err
+	}
+	rotaKeys[ledgerKey] = ledgerEntry
+
+	log.Printf("Confirming the selection of %q as %s for the day and updating the db with the new accrued number details", memberName, role)
+	err = t.writeAssignments(rotaKeys, nil)
 	if err != nil {
 		log.Printf("error writing to db: %v", err)
+		return err
 	}
-	return err
+	t.record(metrics.Confirmed, role)
+	return nil
+}
+
+func (t Team) OverridePersonPickedForToday(memberName string, actor string) error {
+	return t.OverridePersonPickedForRole(t.roles[0], memberName, actor)
 }
 
-func (t Team) OverridePersonPickedForToday(memberName string) error {
+func (t Team) OverridePersonPickedForRole(role string, memberName string, actor string) error {
+	if err := t.checkRole(role); err != nil {
+		return err
+	}
+
 	rotaKeys := make(map[string][]byte)
 
-	personAssignedForTheDay := t.PersonPickedOnTheDay(time.Now())
+	personAssignedForTheDay := t.PersonPickedOnTheDayForRole(t.now(), role)
 
 	if personAssignedForTheDay == "UNKNOWN" {
-		return t.SetPersonPickedForToday(memberName)
+		return t.SetPersonPickedForRole(role, memberName, actor)
+	}
+
+	if personAssignedForTheDay == memberName {
+		log.Printf("%s is already assigned as %s for the day. Nothing to override", memberName, role)
+		return nil
+	}
+
+	// The rest of the displaced person's shift is handed over, starting today
+	remainingDays, err := t.unassign(rotaKeys, personAssignedForTheDay, role, t.now())
+	if err != nil {
+		return err
+	}
+
+	if err := t.checkNotCoveringAnotherRole(memberName, role, t.now(), remainingDays); err != nil {
+		return err
+	}
+
+	assignment := t.assign(rotaKeys, memberName, role, t.now(), remainingDays)
+	assignment.PreviousCronRun = t.latestCronRun()
+	rotaKeys[t.LatestCronRunKey()] = []byte(t.today())
+	assignment.Action = ActionOverride
+	assignment.Actor = actor
+	assignment.PreviousValue = personAssignedForTheDay
+
+	ledgerKey, ledgerEntry, err := t.ledgerEntry(assignment)
+	if err != nil {
+		return err
+	}
+	rotaKeys[ledgerKey] = ledgerEntry
+
+	if err := t.writeAssignments(rotaKeys, nil); err != nil {
+		return err
+	}
+	t.record(metrics.Overridden, role)
+	return nil
+}
+
+// Roles lists the roles filled on every slot, in the order they are picked
+func (t Team) Roles() []string {
+	return append([]string{}, t.roles...)
+}
+
+// IsHoliday tells whether nobody covers the rota on the day, by the team's calendar
+func (t Team) IsHoliday(day time.Time) (bool, string) {
+	return t.isHoliday(day)
+}
+
+func (t Team) checkRole(role string) error {
+	for _, teamRole := range t.roles {
+		if teamRole == role {
+			return nil
+		}
 	}
+	return fmt.Errorf("%s is not one of the roles %v", role, t.roles)
+}
 
-	pickedDaysAsBytes, _ := t.db.Read(t.AccruedDaysCounterKey(personAssignedForTheDay))
-	adjustedPickedDays := uint16(0)
-	pickedDays := bytesToUint(pickedDaysAsBytes)
-	if pickedDays > 0 {
-		adjustedPickedDays = pickedDays - 1
+// checkNotCoveringAnotherRole makes sure one person does not end up covering two roles on the same day
+func (t Team) checkNotCoveringAnotherRole(memberName, role string, start time.Time, days int) error {
+	for _, day := range shiftDates(start, days) {
+		for _, otherRole := range t.roles {
+			if otherRole != role && t.PersonPickedOnTheDayForRole(day, otherRole) == memberName {
+				return fmt.Errorf("%s is already assigned as %s on %s", memberName, otherRole, day.Format("02-01-2006"))
+			}
+		}
 	}
+	return nil
+}
 
-	rotaKeys[t.AccruedDaysCounterKey(personAssignedForTheDay)] = uintToBytes(adjustedPickedDays)
-	// This is incorrect - need to traverse through the history and get the date this person was previously picked
-	rotaKeys[t.LatestDayPickedKey(personAssignedForTheDay)] = []byte("31-12-2006")
+// The first role keeps the keys used before teams had more than one role
+func (t Team) isPrimary(role string) bool {
+	return role == t.roles[0]
+}
 
-	currentlyAccruedDays, _ := t.db.Read(t.AccruedDaysCounterKey(memberName))
-	newAccruedDays := uintToBytes(bytesToUint(currentlyAccruedDays) + 1)
+func (t Team) pickedOnDayKey(date time.Time, role string) string {
+	if t.isPrimary(role) {
+		return t.PersonPickedOnDayKey(date)
+	}
+	return t.PersonPickedOnDayForRoleKey(date, role)
+}
 
-	rotaKeys[t.AccruedDaysCounterKey(memberName)] = newAccruedDays
-	rotaKeys[t.LatestDayPickedKey(memberName)] = []byte(today())
-	rotaKeys[t.PersonPickedOnDayKey(time.Now())] = []byte(memberName)
-	rotaKeys[t.LatestCronRunKey()] = []byte(today())
+func (t Team) accruedDaysKey(memberName, role string) string {
+	if t.isPrimary(role) {
+		return t.AccruedDaysCounterKey(memberName)
+	}
+	return t.AccruedDaysCounterForRoleKey(memberName, role)
+}
 
-	return t.db.MultiWrite(rotaKeys)
+func (t Team) seedKey(memberName, role string) string {
+	if t.isPrimary(role) {
+		return t.SeedKey(memberName)
+	}
+	return t.SeedForRoleKey(memberName, role)
 }
 
-func orderedList(teamRotaHistory TeamRotaHistory) TeamRotaHistory {
-	sort.Sort(teamRotaHistory)
+// orderedList sorts the members in the order they are considered for the pick, settling ties by a hash seeded with the team
+func (t Team) orderedList(teamRotaHistory TeamRotaHistory) TeamRotaHistory {
+	seed := fmt.Sprintf("%s:%d", t.name, t.tieBreakSeed)
+	sort.SliceStable(teamRotaHistory, func(i, j int) bool {
+		return ranksBefore(teamRotaHistory[i], teamRotaHistory[j], seed)
+	})
 	return teamRotaHistory
 }
 
-func (t Team) orderedRotaMessage(host string) string {
+func (t Team) orderedRotaMessage(host string, role string) string {
 	orderedRota := ""
-	today := time.Now().Format("02-01-2006")
 
-	for ind, member := range t.OrderedRota() {
-		orderedRota += fmt.Sprintf("%d. %s/rota/confirm/%s/%s \n", ind+1, host, member.Name, today)
+	for ind, member := range t.OrderedRotaForRole(role) {
+		orderedRota += fmt.Sprintf("%d. %s \n", ind+1, t.confirmURL(host, member.Name, role))
 	}
 
 	return orderedRota
 }
+
+// confirmURL is the link confirming the member for the role today. The role is left out for the first role
+func (t Team) confirmURL(host, memberName, role string) string {
+	confirmURL := fmt.Sprintf("%s/rota/confirm/%s/%s", t.teamURL(host), memberName, t.today())
+	if !t.isPrimary(role) {
+		confirmURL += "?role=" + url.QueryEscape(role)
+	}
+	return confirmURL
+}
+
+// declineURL is the link for the member to decline the role today. A reason can be added to it
+func (t Team) declineURL(host, memberName, role string) string {
+	return strings.Replace(t.confirmURL(host, memberName, role), "/rota/confirm/", "/rota/decline/", 1)
+}
+
+// teamURL is the base of the team's endpoints, as served by the rota manager
+func (t Team) teamURL(host string) string {
+	return host + "/teams/" + t.name
+}
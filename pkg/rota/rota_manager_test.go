@@ -2,10 +2,16 @@ package rota_test
 
 import (
 	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http/httptest"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/supreethrao/automated-rota-manager/pkg/localdb"
 	"github.com/supreethrao/automated-rota-manager/pkg/rota"
 	"gopkg.in/yaml.v2"
@@ -38,10 +44,34 @@ var _ = Describe("Test suite for logic of picking next", func() {
 			Expect(dbHandle.Remove(myTeam.AccruedDaysCounterKey(member))).To(Succeed())
 			Expect(dbHandle.Remove(myTeam.PersonPickedOnDayKey(time.Now()))).To(Succeed())
 			Expect(dbHandle.Remove(myTeam.LatestDayPickedKey(member))).To(Succeed())
+			Expect(dbHandle.Remove(myTeam.WeightKey(member))).To(Succeed())
+			Expect(dbHandle.Remove(myTeam.TagsKey(member))).To(Succeed())
+			Expect(dbHandle.Remove(myTeam.DeclineCounterKey(member))).To(Succeed())
+			Expect(dbHandle.Remove(myTeam.StateKey(member))).To(Succeed())
+			Expect(dbHandle.Remove(myTeam.ProfileKey(member))).To(Succeed())
+			Expect(dbHandle.Remove(myTeam.GraceKey(member))).To(Succeed())
+			Expect(dbHandle.Remove(myTeam.AccruedDaysCounterForRoleKey(member, "secondary"))).To(Succeed())
+			Expect(dbHandle.Remove(myTeam.PersonPickedOnDayForRoleKey(time.Now(), "secondary"))).To(Succeed())
 			oooFrom, oooTo := myTeam.OutOfOfficeKey(member)
 			Expect(dbHandle.Remove(oooFrom)).To(Succeed())
 			Expect(dbHandle.Remove(oooTo)).To(Succeed())
 		}
+		for day := -7; day <= 14; day++ {
+			Expect(dbHandle.Remove(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, day)))).To(Succeed())
+		}
+		ledger, err := dbHandle.ReadWithPrefix(myTeam.LedgerPrefix())
+		Expect(err).ToNot(HaveOccurred())
+		for key := range ledger {
+			Expect(dbHandle.Remove(key)).To(Succeed())
+		}
+		Expect(dbHandle.Remove(myTeam.PendingPickKey("primary"))).To(Succeed())
+		Expect(dbHandle.Remove(myTeam.DeclinedKey(time.Now(), "primary"))).To(Succeed())
+		Expect(dbHandle.Remove(myTeam.PendingPickKey("secondary"))).To(Succeed())
+		swaps, err := dbHandle.ReadWithPrefix(myTeam.SwapRequestPrefix())
+		Expect(err).ToNot(HaveOccurred())
+		for key := range swaps {
+			Expect(dbHandle.Remove(key)).To(Succeed())
+		}
 		Expect(dbHandle.Write(myTeam.TeamKey(), TestTeamMembersListYaml))
 	})
 
@@ -54,7 +84,7 @@ var _ = Describe("Test suite for logic of picking next", func() {
 	Context("Adding new team members", func() {
 		It("Add new team member adds the member to the list", func() {
 			Expect(myTeam.Add("new member")).To(Succeed())
-			Expect(myTeam.List()).To(Equal([]string{"person1", "person2", "third person", "new member"}))
+			Expect(myTeam.List()).To(Equal([]string{"person1", "person2", "third person", "new-member"}))
 		})
 
 		It("Add new team member should not fail if the member already exists", func() {
@@ -66,7 +96,7 @@ var _ = Describe("Test suite for logic of picking next", func() {
 		It("Add new team member initialise their accrued days counter key to 0", func() {
 			newTeamMember := "fourth person"
 			Expect(myTeam.Add(newTeamMember)).To(Succeed())
-			Expect(myTeam.HistoryOfIndividual(newTeamMember).DaysAccrued).To(Equal(uint16(0)))
+			Expect(myTeam.HistoryOfIndividual(rota.MemberID(newTeamMember)).DaysAccrued).To(Equal(0.0))
 		})
 
 		It("Adding an existing team member again should not reset the accrued days ", func() {
@@ -74,7 +104,7 @@ var _ = Describe("Test suite for logic of picking next", func() {
 			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey(existingTeamMember), Uint16ToBytes(7))).To(Succeed())
 
 			Expect(myTeam.Add(existingTeamMember)).To(Succeed())
-			Expect(myTeam.HistoryOfIndividual(existingTeamMember).DaysAccrued).To(Equal(uint16(7)))
+			Expect(myTeam.HistoryOfIndividual(existingTeamMember).DaysAccrued).To(Equal(7.0))
 		})
 	})
 
@@ -87,6 +117,124 @@ var _ = Describe("Test suite for logic of picking next", func() {
 			Expect(myTeam.Remove("non-existent person")).To(Succeed())
 			Expect(myTeam.List()).To(Equal([]string{"person1", "person2", "third person"}))
 		})
+		It("Removing a member archives them with their history", func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Float64ToBytes(5))).To(Succeed())
+			Expect(myTeam.Remove("third person")).To(Succeed())
+
+			Expect(myTeam.Archived()).To(Equal([]string{"third person"}))
+			Expect(myTeam.HistoryOfIndividual("third person").State).To(Equal(rota.StateArchived))
+			Expect(myTeam.HistoryOfIndividual("third person").DaysAccrued).To(Equal(5.0))
+		})
+	})
+
+	Context("Member profiles", func() {
+		BeforeEach(func() {
+			for _, key := range []string{"third-person", "fifth-member"} {
+				Expect(dbHandle.Remove(myTeam.AccruedDaysCounterKey(key))).To(Succeed())
+				Expect(dbHandle.Remove(myTeam.LatestDayPickedKey(key))).To(Succeed())
+				Expect(dbHandle.Remove(myTeam.ProfileKey(key))).To(Succeed())
+			}
+		})
+
+		It("Adds a member with the ID taken from their display name", func() {
+			profile, err := myTeam.SaveProfile(rota.Profile{DisplayName: "Fifth Member", SlackUserID: "U123", Timezone: "Europe/London"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(profile.ID).To(Equal("fifth-member"))
+			Expect(myTeam.List()).To(Equal([]string{"person1", "person2", "third person", "fifth-member"}))
+		})
+
+		It("A member keeps their ID when their display name is corrected", func() {
+			profile, err := myTeam.SaveProfile(rota.Profile{ID: "fifth-member", DisplayName: "Fifth Membr"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("fifth-member"), Float64ToBytes(2))).To(Succeed())
+
+			profile.DisplayName = "Fifth Member"
+			Expect(myTeam.SaveProfile(profile)).To(Equal(profile))
+			Expect(myTeam.Profile("fifth-member").DisplayName).To(Equal("Fifth Member"))
+			Expect(myTeam.HistoryOfIndividual("fifth-member").DaysAccrued).To(Equal(2.0))
+		})
+
+		It("Resolves any name making a member's ID to the member, and rejects the rest", func() {
+			_, err := myTeam.SaveProfile(rota.Profile{DisplayName: "Fifth Member"})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(myTeam.Resolve("Fifth Member")).To(Equal("fifth-member"))
+			Expect(myTeam.Resolve("fifth-member")).To(Equal("fifth-member"))
+			Expect(myTeam.Resolve("Third Person")).To(Equal("third person"))
+			_, err = myTeam.Resolve("Sixth Member")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Updates the profile of a member not yet migrated rather than adding them again", func() {
+			_, err := myTeam.SaveProfile(rota.Profile{ID: "third-person", DisplayName: "Third Person", SlackUserID: "U333"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(myTeam.List()).To(Equal([]string{"person1", "person2", "third person"}))
+			Expect(myTeam.Profile("third person").SlackUserID).To(Equal("U333"))
+		})
+
+		It("Rejects an invalid timezone", func() {
+			_, err := myTeam.SaveProfile(rota.Profile{ID: "fifth-member", Timezone: "Mars/Olympus"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Migrates members keyed by their name over to their ID", func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Float64ToBytes(3))).To(Succeed())
+			Expect(myTeam.SetPersonPickedForToday("third person", "tester")).To(Succeed())
+
+			Expect(myTeam.MigrateToIDs(false)).To(Equal([]rota.Migration{{Name: "third person", ID: "third-person"}}))
+			Expect(myTeam.List()).To(ContainElement("third person"))
+
+			Expect(myTeam.MigrateToIDs(true)).To(HaveLen(1))
+			Expect(myTeam.List()).To(Equal([]string{"person1", "person2", "third-person"}))
+			Expect(myTeam.HistoryOfIndividual("third-person").DaysAccrued).To(Equal(4.0))
+			Expect(myTeam.HistoryOfIndividual("third-person").LatestPickedDay).To(Equal(Today()))
+			Expect(myTeam.PersonPickedOnTheDay(time.Now())).To(Equal("third-person"))
+			Expect(myTeam.Profile("third-person").DisplayName).To(Equal("third person"))
+
+			ledger, err := myTeam.LedgerOfIndividual("third-person")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ledger).To(HaveLen(1))
+
+			Expect(myTeam.MigrateToIDs(true)).To(BeEmpty())
+		})
+	})
+
+	Context("Member lifecycle", func() {
+		BeforeEach(func() {
+			Expect(dbHandle.Remove(myTeam.LatestCronRunKey())).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(1))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(4))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Float64ToBytes(6))).To(Succeed())
+		})
+
+		It("A paused member is skipped until they are resumed", func() {
+			Expect(myTeam.Pause("person1", time.Time{})).To(Succeed())
+
+			selection, err := myTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Picks).To(Equal([]string{"person2"}))
+			Expect(selection.Skipped).To(Equal([]rota.Skip{{Member: "person1", Role: "primary", Reason: "paused"}}))
+
+			Expect(myTeam.Resume("person1")).To(Succeed())
+			Expect(myTeam.HistoryOfIndividual("person1").State).To(Equal(rota.StateActive))
+		})
+
+		It("A paused member is active again from their resume day", func() {
+			Expect(myTeam.Pause("person1", time.Now().AddDate(0, 0, 1))).To(Succeed())
+			Expect(myTeam.HistoryOfIndividual("person1").State).To(Equal(rota.StatePaused))
+
+			Expect(myTeam.Pause("person1", time.Now())).To(Succeed())
+			Expect(myTeam.HistoryOfIndividual("person1").State).To(Equal(rota.StateActive))
+		})
+
+		It("A returning member is lifted to the least loaded member", func() {
+			Expect(myTeam.Archive("person1")).To(Succeed())
+			Expect(myTeam.Add("person1")).To(Succeed())
+
+			Expect(myTeam.List()).To(Equal([]string{"person2", "third person", "person1"}))
+			Expect(myTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(4.0))
+			Expect(myTeam.Archived()).To(BeEmpty())
+		})
 	})
 
 	Context("Setting the person picked", func() {
@@ -95,13 +243,105 @@ var _ = Describe("Test suite for logic of picking next", func() {
 			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Uint16ToBytes(7))).To(Succeed())
 
 			//when
-			Expect(myTeam.SetPersonPickedForToday("person1")).To(Succeed())
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
 
 			//then
-			Expect(dbHandle.Read(myTeam.AccruedDaysCounterKey("person1"))).To(Equal(Uint16ToBytes(8)))
+			Expect(dbHandle.Read(myTeam.AccruedDaysCounterKey("person1"))).To(Equal(Float64ToBytes(8)))
 			Expect(dbHandle.Read(myTeam.LatestDayPickedKey("person1"))).To(Equal([]byte(Today())))
 			Expect(dbHandle.Read(myTeam.PersonPickedOnDayKey(time.Now()))).To(Equal([]byte("person1")))
 		})
+
+		It("Confirming and overriding are recorded in the ledger", func() {
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(myTeam.OverridePersonPickedForToday("person2", "admin")).To(Succeed())
+
+			ledger, err := myTeam.Ledger()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ledger).To(HaveLen(2))
+
+			Expect(ledger[0].Member).To(Equal("person1"))
+			Expect(ledger[0].Action).To(Equal(rota.ActionConfirm))
+			Expect(ledger[0].Actor).To(Equal("tester"))
+			Expect(ledger[0].Date).To(Equal(Today()))
+
+			Expect(ledger[1].Member).To(Equal("person2"))
+			Expect(ledger[1].Action).To(Equal(rota.ActionOverride))
+			Expect(ledger[1].Actor).To(Equal("admin"))
+			Expect(ledger[1].PreviousValue).To(Equal("person1"))
+		})
+
+		It("Hands out distinct ledger sequence numbers to writers running at the same time", func() {
+			var lock sync.Mutex
+			sequences := make(map[uint64]bool)
+			var writers sync.WaitGroup
+			for writer := 0; writer < 10; writer++ {
+				writers.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer writers.Done()
+					for entry := 0; entry < 10; entry++ {
+						sequence, err := dbHandle.NextSeq(myTeam.LedgerSequenceKey())
+						Expect(err).ToNot(HaveOccurred())
+						lock.Lock()
+						sequences[sequence] = true
+						lock.Unlock()
+					}
+				}()
+			}
+			writers.Wait()
+			Expect(sequences).To(HaveLen(100))
+		})
+	})
+
+	Context("Overriding the person picked", func() {
+		BeforeEach(func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Uint16ToBytes(4))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Uint16ToBytes(6))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Uint16ToBytes(3))).To(Succeed())
+
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(DaysBeforeToday(3)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person2"), []byte(DaysBeforeToday(4)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("third person"), []byte(DaysBeforeToday(5)))).To(Succeed())
+		})
+
+		It("Restores the accrued days and the previous picked day of the person displaced", func() {
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(myTeam.OverridePersonPickedForToday("person2", "tester")).To(Succeed())
+
+			Expect(myTeam.HistoryOfIndividual("person1")).To(Equal(rota.IndividualHistory{Name: "person1", DaysAccrued: 4, LatestPickedDay: DaysBeforeToday(3), Weight: 1, State: rota.StateActive}))
+			Expect(myTeam.HistoryOfIndividual("person2")).To(Equal(rota.IndividualHistory{Name: "person2", DaysAccrued: 7, LatestPickedDay: Today(), Weight: 1, State: rota.StateActive}))
+			Expect(myTeam.PersonPickedOnTheDay(time.Now())).To(Equal("person2"))
+		})
+
+		It("Unwinds each override when overridden repeatedly on the same day", func() {
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(myTeam.OverridePersonPickedForToday("person2", "tester")).To(Succeed())
+			Expect(myTeam.OverridePersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(myTeam.OverridePersonPickedForToday("third person", "tester")).To(Succeed())
+
+			Expect(myTeam.HistoryOfIndividual("person1")).To(Equal(rota.IndividualHistory{Name: "person1", DaysAccrued: 4, LatestPickedDay: DaysBeforeToday(3), Weight: 1, State: rota.StateActive}))
+			Expect(myTeam.HistoryOfIndividual("person2")).To(Equal(rota.IndividualHistory{Name: "person2", DaysAccrued: 6, LatestPickedDay: DaysBeforeToday(4), Weight: 1, State: rota.StateActive}))
+			Expect(myTeam.HistoryOfIndividual("third person")).To(Equal(rota.IndividualHistory{Name: "third person", DaysAccrued: 4, LatestPickedDay: Today(), Weight: 1, State: rota.StateActive}))
+			Expect(myTeam.PersonPickedOnTheDay(time.Now())).To(Equal("third person"))
+		})
+
+		It("Overriding with the person already assigned changes nothing", func() {
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(myTeam.OverridePersonPickedForToday("person1", "tester")).To(Succeed())
+
+			Expect(myTeam.HistoryOfIndividual("person1")).To(Equal(rota.IndividualHistory{Name: "person1", DaysAccrued: 5, LatestPickedDay: Today(), Weight: 1, State: rota.StateActive}))
+		})
+
+		It("A displaced person who was never picked before goes back to never having been picked", func() {
+			Expect(dbHandle.Remove(myTeam.LatestDayPickedKey("person1"))).To(Succeed())
+
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(myTeam.OverridePersonPickedForToday("person2", "tester")).To(Succeed())
+
+			Expect(myTeam.HistoryOfIndividual("person1").LatestPickedDay).To(Equal("N/A"))
+			_, err := dbHandle.Read(myTeam.LatestDayPickedKey("person1"))
+			Expect(err).To(HaveOccurred())
+		})
 	})
 
 	Context("Batch add of team members or initialise the whole team", func() {
@@ -121,17 +361,17 @@ var _ = Describe("Test suite for logic of picking next", func() {
 	Context("Test sorting logic", func() {
 		It("Should be sorted based on the accrued days", func() {
 			teamHistory := rota.TeamRotaHistory{
-				{"person1", 5, Yesterday()},
-				{"person2", 3, Yesterday()},
-				{"person3", 7, Yesterday()},
-				{"person4", 2, Yesterday()},
+				{Name: "person1", DaysAccrued: 5, LatestPickedDay: Yesterday()},
+				{Name: "person2", DaysAccrued: 3, LatestPickedDay: Yesterday()},
+				{Name: "person3", DaysAccrued: 7, LatestPickedDay: Yesterday()},
+				{Name: "person4", DaysAccrued: 2, LatestPickedDay: Yesterday()},
 			}
 
 			expectedTeamHistory := rota.TeamRotaHistory{
-				{"person4", 2, Yesterday()},
-				{"person2", 3, Yesterday()},
-				{"person1", 5, Yesterday()},
-				{"person3", 7, Yesterday()},
+				{Name: "person4", DaysAccrued: 2, LatestPickedDay: Yesterday()},
+				{Name: "person2", DaysAccrued: 3, LatestPickedDay: Yesterday()},
+				{Name: "person1", DaysAccrued: 5, LatestPickedDay: Yesterday()},
+				{Name: "person3", DaysAccrued: 7, LatestPickedDay: Yesterday()},
 			}
 
 			sort.Sort(teamHistory)
@@ -139,6 +379,71 @@ var _ = Describe("Test suite for logic of picking next", func() {
 		})
 	})
 
+	Context("Tie breaking", func() {
+		It("Members with the same load are ordered by their latest picked day and then the day they joined", func() {
+			teamHistory := rota.TeamRotaHistory{
+				{Name: "person1", DaysAccrued: 3, LatestPickedDay: Yesterday(), JoinedOn: DaysBeforeToday(30)},
+				{Name: "person2", DaysAccrued: 3, LatestPickedDay: DaysBeforeToday(5), JoinedOn: DaysBeforeToday(10)},
+				{Name: "person3", DaysAccrued: 3, LatestPickedDay: DaysBeforeToday(5), JoinedOn: DaysBeforeToday(20)},
+				{Name: "person4", DaysAccrued: 3, LatestPickedDay: "N/A", JoinedOn: Yesterday()},
+			}
+
+			sort.Sort(teamHistory)
+			Expect([]string{teamHistory[0].Name, teamHistory[1].Name, teamHistory[2].Name, teamHistory[3].Name}).To(Equal([]string{"person4", "person3", "person2", "person1"}))
+		})
+
+		It("The remaining ties are settled the same way whatever order the members are listed in", func() {
+			Expect(dbHandle.Write(myTeam.TeamKey(), []byte("members:\n- third person\n- person2\n- person1\n"))).To(Succeed())
+			reversed := myTeam.OrderedRota()
+
+			Expect(dbHandle.Write(myTeam.TeamKey(), TestTeamMembersListYaml)).To(Succeed())
+			Expect(myTeam.OrderedRota()).To(Equal(reversed))
+		})
+	})
+
+	Context("Weighted participation", func() {
+		It("Should be sorted based on the accrued days normalised by weight", func() {
+			teamHistory := rota.TeamRotaHistory{
+				{Name: "person1", DaysAccrued: 5, LatestPickedDay: Yesterday(), Weight: 1},
+				{Name: "person2", DaysAccrued: 3, LatestPickedDay: Yesterday(), Weight: 0.5},
+				{Name: "person3", DaysAccrued: 4, LatestPickedDay: Yesterday(), Weight: 1},
+			}
+
+			sort.Sort(teamHistory)
+			Expect([]string{teamHistory[0].Name, teamHistory[1].Name, teamHistory[2].Name}).To(Equal([]string{"person3", "person1", "person2"}))
+		})
+
+		It("Part timers are passed over until their normalised load is the lowest", func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Uint16ToBytes(4))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Uint16ToBytes(6))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Uint16ToBytes(3))).To(Succeed())
+
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(DaysBeforeToday(3)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person2"), []byte(DaysBeforeToday(4)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("third person"), []byte(DaysBeforeToday(5)))).To(Succeed())
+
+			Expect(myTeam.SetWeight("third person", 0.5)).To(Succeed())
+
+			Expect(NextPicks(myTeam)).To(Equal([]string{"person1"}))
+			Expect(myTeam.HistoryOfIndividual("third person").Weight).To(Equal(0.5))
+		})
+
+		It("Weights have to be greater than 0", func() {
+			Expect(myTeam.SetWeight("person1", 0)).ToNot(Succeed())
+			Expect(myTeam.SetWeight("person1", -1)).ToNot(Succeed())
+		})
+
+		It("Confirming accrues a whole day on top of a legacy counter", func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Uint16ToBytes(2))).To(Succeed())
+			Expect(myTeam.SetWeight("person1", 0.5)).To(Succeed())
+
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+
+			Expect(myTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(3.0))
+			Expect(myTeam.HistoryOfIndividual("person1").Load()).To(Equal(6.0))
+		})
+	})
+
 	Context("Test picking based on fair rotation", func() {
 		It("Next person is the person who has been fewer accrued days", func() {
 			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Uint16ToBytes(4))).To(Succeed())
@@ -151,23 +456,1074 @@ var _ = Describe("Test suite for logic of picking next", func() {
 
 			nextPerson, err := myTeam.Next()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(nextPerson).To(Equal("third person"))
+			Expect(nextPerson.Primary()).To(Equal("third person"))
+		})
+
+		It("Should wait for 2 other people to be picked regardless of number of accrued days", func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Uint16ToBytes(4))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Uint16ToBytes(6))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Uint16ToBytes(3))).To(Succeed())
+
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -1)), []byte("person1"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -2)), []byte("third person"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -3)), []byte("person2"))).To(Succeed())
+
+			nextPerson, err := myTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nextPerson.Primary()).To(Equal("person2"))
+		})
+
+		It("Explains how every member was considered", func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(1))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Float64ToBytes(3))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -1)), []byte("person1"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(Yesterday()))).To(Succeed())
+
+			selection, err := myTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Trace).To(HaveLen(3))
+
+			Expect(selection.Trace[0].Member).To(Equal("person1"))
+			Expect(selection.Trace[0].Decision).To(Equal(rota.DecisionCoolingDown))
+			Expect(selection.Trace[0].CoolingDown).To(BeTrue())
+			Expect(selection.Trace[0].DaysSincePicked).To(Equal(1))
+			Expect(selection.Trace[1].Member).To(Equal("person2"))
+			Expect(selection.Trace[1].Decision).To(Equal(rota.DecisionPicked))
+			Expect(selection.Trace[1].DaysSincePicked).To(Equal(-1))
+			Expect(selection.Trace[2].Member).To(Equal("third person"))
+			Expect(selection.Trace[2].Decision).To(Equal(rota.DecisionNotReached))
+			Expect(selection.Explain()).To(ContainSubstring("person1 as primary - cooling down since their last pick. Load 1, picked 1 days ago, cooling down"))
+		})
+
+		It("Explains that the pick falls on a holiday", func() {
+			holidayTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{
+				IsHoliday: func(time.Time) (bool, string) {
+					return true, "Boxing Day"
+				},
+			})
+
+			selection, err := holidayTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Trace[0].Holiday).To(Equal("Boxing Day"))
+			Expect(selection.Explain()).To(ContainSubstring(selection.Trace[0].Member + " as primary - picked. Load 0, never picked, pick falls on Boxing Day"))
+		})
+
+		It("Gives way to someone cooling down when nobody else can be picked", func() {
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -1)), []byte("person1"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -2)), []byte("person2"))).To(Succeed())
+			Expect(myTeam.SetOutOfOffice("third person", time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, 1))).To(Succeed())
+
+			selection, err := myTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Primary()).To(Or(Equal("person1"), Equal("person2")))
+		})
+
+		It("Applies the cooldown whatever the strategy", func() {
+			strategy, err := rota.NewStrategy(rota.RoundRobin, 0)
+			Expect(err).ToNot(HaveOccurred())
+			roundRobinTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Strategy: strategy})
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -2)), []byte("person2"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person2"), []byte(DaysBeforeToday(2)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -1)), []byte("person1"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(Yesterday()))).To(Succeed())
+
+			// Round robin carries on after person1 with person2, who is still cooling down
+			selection, err := roundRobinTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Primary()).To(Equal("third person"))
+		})
+
+		It("Caps the cooldown at one fewer than the active members", func() {
+			cooldown := 5
+			longCooldownTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Cooldown: &cooldown})
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -1)), []byte("person1"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -2)), []byte("third person"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -3)), []byte("person2"))).To(Succeed())
+
+			nextPerson, err := longCooldownTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nextPerson.Primary()).To(Equal("person2"))
+
+			Expect(longCooldownTeam.Pause("person2", time.Time{})).To(Succeed())
+			nextPerson, err = longCooldownTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(nextPerson.Primary()).To(Equal("third person"))
+		})
+	})
+
+	Context("Selection strategies", func() {
+		var teamWithStrategy = func(name string) *rota.Team {
+			strategy, err := rota.NewStrategy(name, 42)
+			Expect(err).ToNot(HaveOccurred())
+			return rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Strategy: strategy})
+		}
+
+		BeforeEach(func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Uint16ToBytes(4))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Uint16ToBytes(6))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Uint16ToBytes(3))).To(Succeed())
+
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(DaysBeforeToday(5)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person2"), []byte(DaysBeforeToday(3)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("third person"), []byte(DaysBeforeToday(4)))).To(Succeed())
+		})
+
+		It("Round robin picks the member after the one picked last", func() {
+			Expect(NextPicks(teamWithStrategy(rota.RoundRobin))).To(Equal([]string{"third person"}))
+		})
+
+		It("Round robin wraps around and skips people who are out of office", func() {
+			oooFrom, oooTo := myTeam.OutOfOfficeKey("third person")
+			Expect(dbHandle.Write(oooFrom, timeToBytes(time.Now()))).To(Succeed())
+			Expect(dbHandle.Write(oooTo, timeToBytes(time.Now()))).To(Succeed())
+
+			Expect(NextPicks(teamWithStrategy(rota.RoundRobin))).To(Equal([]string{"person1"}))
+		})
+
+		It("Least recently picked ignores the accrued days", func() {
+			Expect(NextPicks(teamWithStrategy(rota.LeastRecentlyPicked))).To(Equal([]string{"person1"}))
 		})
 
-		It("Should have a couple of days breather regardless of number of accrued days", func() {
-			Expect(dbHandle.Write(myTeam.LatestCronRunKey(), []byte(Yesterday())))
+		It("Fair random gives the same answer through the day", func() {
+			fairRandomTeam := teamWithStrategy(rota.FairRandom)
+			firstPick := NextPicks(fairRandomTeam)
+			Expect(testTeamMembers).To(ContainElement(firstPick[0]))
+
+			for i := 0; i < 5; i++ {
+				Expect(NextPicks(fairRandomTeam)).To(Equal(firstPick))
+			}
+		})
+
+		It("Unknown strategies are rejected", func() {
+			_, err := rota.NewStrategy("whoever-shouts-loudest", 0)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Picking a primary and a secondary", func() {
+		var supportTeam *rota.Team
+
+		BeforeEach(func() {
+			supportTeam = rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Roles: []string{"primary", "secondary"}})
 
 			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Uint16ToBytes(4))).To(Succeed())
 			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Uint16ToBytes(6))).To(Succeed())
 			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Uint16ToBytes(3))).To(Succeed())
 
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterForRoleKey("person1", "secondary"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterForRoleKey("person2", "secondary"), Float64ToBytes(1))).To(Succeed())
+
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(DaysBeforeToday(3)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person2"), []byte(DaysBeforeToday(4)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("third person"), []byte(DaysBeforeToday(5)))).To(Succeed())
+		})
+
+		It("Picks a different person for each role in order", func() {
+			selection, err := supportTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Roles).To(Equal([]string{"primary", "secondary"}))
+			Expect(selection.Picks).To(Equal([]string{"third person", "person2"}))
+		})
+
+		It("Tracks the accrued days of each role separately", func() {
+			Expect(supportTeam.SetPersonPickedForRole("primary", "third person", "tester")).To(Succeed())
+			Expect(supportTeam.SetPersonPickedForRole("secondary", "person2", "tester")).To(Succeed())
+
+			Expect(supportTeam.HistoryOfIndividualForRole("third person", "primary").DaysAccrued).To(Equal(4.0))
+			Expect(supportTeam.HistoryOfIndividualForRole("third person", "secondary").DaysAccrued).To(Equal(0.0))
+			Expect(supportTeam.HistoryOfIndividualForRole("person2", "primary").DaysAccrued).To(Equal(6.0))
+			Expect(supportTeam.HistoryOfIndividualForRole("person2", "secondary").DaysAccrued).To(Equal(2.0))
+
+			Expect(supportTeam.PersonPickedOnTheDayForRole(time.Now(), "primary")).To(Equal("third person"))
+			Expect(supportTeam.PersonPickedOnTheDayForRole(time.Now(), "secondary")).To(Equal("person2"))
+		})
+
+		It("The same person cannot cover two roles on the same day", func() {
+			Expect(supportTeam.SetPersonPickedForRole("primary", "person1", "tester")).To(Succeed())
+			Expect(supportTeam.SetPersonPickedForRole("secondary", "person1", "tester")).ToNot(Succeed())
+			Expect(supportTeam.OverridePersonPickedForRole("secondary", "person1", "tester")).ToNot(Succeed())
+		})
+
+		It("Overriding a role only reverts that role", func() {
+			Expect(supportTeam.SetPersonPickedForRole("primary", "third person", "tester")).To(Succeed())
+			Expect(supportTeam.SetPersonPickedForRole("secondary", "person2", "tester")).To(Succeed())
+			Expect(supportTeam.OverridePersonPickedForRole("secondary", "person1", "tester")).To(Succeed())
+
+			Expect(supportTeam.HistoryOfIndividualForRole("person2", "secondary").DaysAccrued).To(Equal(1.0))
+			Expect(supportTeam.HistoryOfIndividualForRole("person2", "primary").LatestPickedDay).To(Equal(DaysBeforeToday(4)))
+			Expect(supportTeam.HistoryOfIndividualForRole("person1", "secondary").DaysAccrued).To(Equal(3.0))
+			Expect(supportTeam.PersonPickedOnTheDayForRole(time.Now(), "primary")).To(Equal("third person"))
+		})
+
+		It("Unknown roles are rejected", func() {
+			Expect(supportTeam.SetPersonPickedForRole("tertiary", "person1", "tester")).ToNot(Succeed())
+		})
+
+		It("Seeds new members in every role from that role's own history", func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterForRoleKey("third person", "secondary"), Float64ToBytes(3))).To(Succeed())
+
+			Expect(supportTeam.Add("new member")).To(Succeed())
+			Expect(supportTeam.HistoryOfIndividualForRole("new-member", "primary").DaysAccrued).To(Equal(3.0))
+			Expect(supportTeam.HistoryOfIndividualForRole("new-member", "secondary").DaysAccrued).To(Equal(1.0))
+
+			discrepancies, err := supportTeam.Reconcile(false)
+			Expect(err).ToNot(HaveOccurred())
+			for _, discrepancy := range discrepancies {
+				Expect(discrepancy.Member).ToNot(Equal("new-member"))
+			}
+		})
+	})
+
+	Context("Multi day shifts", func() {
+		var weeklyTeam *rota.Team
+		var bankHoliday time.Time
+
+		BeforeEach(func() {
+			bankHoliday = time.Now().AddDate(0, 0, 1)
+			for bankHoliday.Weekday() == time.Saturday || bankHoliday.Weekday() == time.Sunday {
+				bankHoliday = bankHoliday.AddDate(0, 0, 1)
+			}
+
+			weeklyTeam = rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{
+				ShiftDays: 7,
+				IsHoliday: func(day time.Time) (bool, string) {
+					if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+						return true, "Weekend"
+					}
+					return day.Format("02-01-2006") == bankHoliday.Format("02-01-2006"), "Bank holiday"
+				},
+			})
+		})
+
+		It("Covers every day of the shift and accrues the working days in it", func() {
+			Expect(weeklyTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+
+			Expect(weeklyTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(4.0))
+			Expect(weeklyTeam.HistoryOfIndividual("person1").LatestPickedDay).To(Equal(Today()))
+			for day := 0; day < 7; day++ {
+				Expect(weeklyTeam.PersonPickedOnTheDay(time.Now().AddDate(0, 0, day))).To(Equal("person1"))
+			}
+			Expect(weeklyTeam.PersonPickedOnTheDay(time.Now().AddDate(0, 0, 7))).To(Equal("UNKNOWN"))
+		})
+
+		It("Overriding on the first day hands the whole shift over", func() {
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(DaysBeforeToday(14)))).To(Succeed())
+
+			Expect(weeklyTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(weeklyTeam.OverridePersonPickedForToday("person2", "tester")).To(Succeed())
+
+			Expect(weeklyTeam.HistoryOfIndividual("person1")).To(Equal(rota.IndividualHistory{Name: "person1", DaysAccrued: 0, LatestPickedDay: DaysBeforeToday(14), Weight: 1, State: rota.StateActive}))
+			Expect(weeklyTeam.HistoryOfIndividual("person2").DaysAccrued).To(Equal(4.0))
+			Expect(weeklyTeam.PersonPickedOnTheDay(time.Now().AddDate(0, 0, 6))).To(Equal("person2"))
+		})
+
+		It("A shift cannot run into someone else's shift", func() {
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, 3)), []byte("person2"))).To(Succeed())
+
+			Expect(weeklyTeam.SetPersonPickedForToday("person1", "tester")).ToNot(Succeed())
+		})
+	})
+
+	Context("Tag requirements", func() {
+		var adminTeam *rota.Team
+
+		BeforeEach(func() {
+			requirement, err := rota.ParseRequirement("k8s-admin && (dba || !on-site)")
+			Expect(err).ToNot(HaveOccurred())
+			adminTeam = rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Requirement: requirement})
+
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(1))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Float64ToBytes(3))).To(Succeed())
+		})
+
+		It("Skips members who do not meet the requirement and records why", func() {
+			Expect(adminTeam.SetTags("person1", []string{"k8s-admin", "on-site"})).To(Succeed())
+			Expect(adminTeam.SetTags("person2", []string{"k8s-admin"})).To(Succeed())
+
+			selection, err := adminTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Picks).To(Equal([]string{"person2"}))
+			Expect(selection.Skipped).To(Equal([]rota.Skip{{Member: "person1", Role: "primary", Reason: "does not meet the requirement (k8s-admin && (dba || !on-site))"}}))
+		})
+
+		It("Nobody is picked when no one meets the requirement", func() {
+			Expect(NextPicks(adminTeam)).To(Equal([]string{"UNKNOWN-UNKNOWN"}))
+			Expect(NextPicks(myTeam)).To(Equal([]string{"person1"}))
+		})
+
+		It("Tags are replaced and read back", func() {
+			Expect(myTeam.Tags("person1")).To(BeEmpty())
+			Expect(myTeam.SetTags("person1", []string{"dba"})).To(Succeed())
+			Expect(myTeam.SetTags("person1", []string{"k8s-admin", "on-site"})).To(Succeed())
+			Expect(myTeam.Tags("person1")).To(Equal([]string{"k8s-admin", "on-site"}))
+			Expect(myTeam.SetTags("person1", []string{"not a tag"})).ToNot(Succeed())
+		})
+
+		It("Invalid requirements are rejected", func() {
+			for _, expression := range []string{"k8s-admin &&", "(dba", "dba & on-site", "dba on-site", "|| dba"} {
+				_, err := rota.ParseRequirement(expression)
+				Expect(err).To(HaveOccurred(), expression)
+			}
+		})
+	})
+
+	Context("Pairing and exclusion rules", func() {
+		teamWithRule := func(roles []string, name string, members []string, tag string) *rota.Team {
+			rule, err := rota.NewRule(name, members, tag)
+			Expect(err).ToNot(HaveOccurred())
+			// Leave the cooldown out of the way of the rules
+			noCooldown := 0
+			return rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Roles: roles, Rules: []rota.Rule{rule}, Cooldown: &noCooldown})
+		}
+
+		BeforeEach(func() {
+			Expect(dbHandle.Remove(myTeam.LatestCronRunKey())).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(1))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Float64ToBytes(3))).To(Succeed())
+		})
+
+		It("Members of a not consecutive rule don't cover slots one after another", func() {
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -1)), []byte("person1"))).To(Succeed())
+			consecutiveTeam := teamWithRule(nil, rota.NotConsecutive, []string{"person1", "person2"}, "")
+
+			selection, err := consecutiveTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Picks).To(Equal([]string{"person1"}))
+			Expect(selection.Skipped).To(Equal([]rota.Skip{{Member: "person2", Role: "primary", Reason: "excluded by rule not_consecutive(person1, person2) as person1 covered the previous slot on " + Yesterday()}}))
+		})
+
+		It("Rules apply to members listed by any name making their ID", func() {
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -1)), []byte("third person"))).To(Succeed())
+			consecutiveTeam := teamWithRule(nil, rota.NotConsecutive, []string{"Third Person", "Person2"}, "")
+
+			selection, err := consecutiveTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Picks).To(Equal([]string{"person1"}))
+			Expect(selection.Skipped[0].Member).To(Equal("person2"))
+		})
+
+		It("Members of a not together rule are not picked for the same slot", func() {
+			Expect(myTeam.SetTags("person1", []string{"trainee"})).To(Succeed())
+			Expect(myTeam.SetTags("person2", []string{"trainee"})).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterForRoleKey("person1", "secondary"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterForRoleKey("person2", "secondary"), Float64ToBytes(1))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterForRoleKey("third person", "secondary"), Float64ToBytes(3))).To(Succeed())
+			traineeTeam := teamWithRule([]string{"primary", "secondary"}, rota.NotTogether, nil, "trainee")
+
+			selection, err := traineeTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Picks).To(Equal([]string{"person2", "third person"}))
+			Expect(selection.Skipped).To(Equal([]rota.Skip{{Member: "person1", Role: "secondary", Reason: "excluded by rule not_together(tag trainee) as person2 is already picked for the slot"}}))
+		})
+
+		It("Members of a not same week rule are not on the rota in the same week", func() {
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now()), []byte("person1"))).To(Succeed())
+			sameWeekTeam := teamWithRule(nil, rota.NotSameWeek, []string{"person1", "person2"}, "")
+
+			selection, err := sameWeekTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Picks).To(Equal([]string{"person1"}))
+			Expect(selection.Skipped[0].Reason).To(Equal("excluded by rule not_same_week(person1, person2) as person1 is on the rota on " + Today()))
+		})
+
+		It("Unknown rules and rules applying to nobody are rejected", func() {
+			_, err := rota.NewRule("not_on_tuesdays", []string{"person1"}, "")
+			Expect(err).To(HaveOccurred())
+			_, err = rota.NewRule(rota.NotTogether, nil, "")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Cancelling today's assignment", func() {
+		BeforeEach(func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(DaysBeforeToday(14)))).To(Succeed())
+		})
+
+		It("Reverts the accrued days, latest picked day and latest cron run", func() {
+			Expect(dbHandle.Write(myTeam.LatestCronRunKey(), []byte(Yesterday()))).To(Succeed())
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+
+			Expect(myTeam.CancelToday("tester")).To(Equal("person1"))
+
+			Expect(myTeam.PersonPickedOnTheDay(time.Now())).To(Equal("UNKNOWN"))
+			Expect(myTeam.HistoryOfIndividual("person1")).To(Equal(rota.IndividualHistory{Name: "person1", DaysAccrued: 2, LatestPickedDay: DaysBeforeToday(14), Weight: 1, State: rota.StateActive}))
+			Expect(dbHandle.Read(myTeam.LatestCronRunKey())).To(Equal([]byte(Yesterday())))
+
+			ledger, err := myTeam.Ledger()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ledger[len(ledger)-1].Action).To(Equal(rota.ActionCancel))
+
+			Expect(myTeam.SetPersonPickedForToday("person2", "tester")).To(Succeed())
+			Expect(myTeam.PersonPickedOnTheDay(time.Now())).To(Equal("person2"))
+		})
+
+		It("Removes the latest cron run if there was none before", func() {
+			Expect(dbHandle.Remove(myTeam.LatestCronRunKey())).To(Succeed())
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+
+			Expect(myTeam.CancelToday("tester")).To(Equal("person1"))
+			_, err := dbHandle.Read(myTeam.LatestCronRunKey())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Keeps the latest cron run while another role is still covered today", func() {
+			supportTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Roles: []string{"primary", "secondary"}})
+			Expect(dbHandle.Write(myTeam.LatestCronRunKey(), []byte(Yesterday()))).To(Succeed())
+			Expect(supportTeam.SetPersonPickedForRole("primary", "person1", "tester")).To(Succeed())
+			Expect(supportTeam.SetPersonPickedForRole("secondary", "person2", "tester")).To(Succeed())
+
+			Expect(supportTeam.CancelTodayForRole("secondary", "tester")).To(Equal("person2"))
+			Expect(dbHandle.Read(myTeam.LatestCronRunKey())).To(Equal([]byte(Today())))
+
+			Expect(supportTeam.CancelTodayForRole("primary", "tester")).To(Equal("person1"))
+			Expect(dbHandle.Read(myTeam.LatestCronRunKey())).To(Equal([]byte(Yesterday())))
+		})
+
+		It("Fails when nobody is assigned", func() {
+			_, err := myTeam.CancelToday("tester")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Recording past assignments", func() {
+		threeDaysAgo := time.Now().AddDate(0, 0, -3)
+
+		BeforeEach(func() {
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person2"), []byte(DaysBeforeToday(10)))).To(Succeed())
+		})
+
+		It("Recording accrues the day but keeps a later latest picked day", func() {
 			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(Yesterday()))).To(Succeed())
+
+			Expect(myTeam.RecordAssignment("person1", threeDaysAgo, "admin")).To(Succeed())
+
+			Expect(myTeam.PersonPickedOnTheDay(threeDaysAgo)).To(Equal("person1"))
+			Expect(myTeam.HistoryOfIndividual("person1")).To(Equal(rota.IndividualHistory{Name: "person1", DaysAccrued: 3, LatestPickedDay: Yesterday(), Weight: 1, State: rota.StateActive}))
+		})
+
+		It("Correcting a recorded date recalculates both members", func() {
+			Expect(myTeam.RecordAssignment("person2", threeDaysAgo, "admin")).To(Succeed())
+			Expect(myTeam.HistoryOfIndividual("person2").LatestPickedDay).To(Equal(DaysBeforeToday(3)))
+
+			Expect(myTeam.RecordAssignment("person1", threeDaysAgo, "admin")).To(Succeed())
+
+			Expect(myTeam.PersonPickedOnTheDay(threeDaysAgo)).To(Equal("person1"))
+			Expect(myTeam.HistoryOfIndividual("person1")).To(Equal(rota.IndividualHistory{Name: "person1", DaysAccrued: 3, LatestPickedDay: DaysBeforeToday(3), Weight: 1, State: rota.StateActive}))
+			Expect(myTeam.HistoryOfIndividual("person2")).To(Equal(rota.IndividualHistory{Name: "person2", DaysAccrued: 2, LatestPickedDay: DaysBeforeToday(10), Weight: 1, State: rota.StateActive}))
+
+			ledger, err := myTeam.Ledger()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ledger[len(ledger)-1].Action).To(Equal(rota.ActionRecord))
+			Expect(ledger[len(ledger)-1].PreviousValue).To(Equal("person2"))
+		})
+
+		It("Future dates cannot be recorded", func() {
+			Expect(myTeam.RecordAssignment("person1", time.Now().AddDate(0, 0, 1), "admin")).ToNot(Succeed())
+		})
+	})
+
+	Context("Reconciling counters with the days recorded", func() {
+		BeforeEach(func() {
+			stored, err := dbHandle.ReadWithPrefix(myTeam.DayKeysPrefix())
+			Expect(err).ToNot(HaveOccurred())
+			for key := range stored {
+				if _, _, ok := myTeam.ParsePersonPickedOnDayKey(key); ok {
+					Expect(dbHandle.Remove(key)).To(Succeed())
+				}
+			}
+			for _, member := range testTeamMembers {
+				Expect(dbHandle.Remove(myTeam.SeedKey(member))).To(Succeed())
+			}
+		})
+
+		It("Lists the counters that disagree and fixes them when applied", func() {
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -5)), []byte("person1"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -1)), []byte("person1"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -3)), []byte("person2"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(7))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(DaysBeforeToday(5)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(1))).To(Succeed())
 			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person2"), []byte(DaysBeforeToday(3)))).To(Succeed())
-			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("third person"), []byte(DayBeforeYesterday()))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.SeedKey("person1"), Float64ToBytes(0))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.SeedKey("person2"), Float64ToBytes(0))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.SeedKey("third person"), Float64ToBytes(2))).To(Succeed())
 
-			nextPerson, err := myTeam.Next()
+			expected := []rota.Discrepancy{
+				{Member: "person1", Role: "primary", Field: "DaysAccrued", Stored: "7", Derived: "2"},
+				{Member: "person1", Field: "LatestPickedDay", Stored: DaysBeforeToday(5), Derived: Yesterday()},
+				{Member: "third person", Role: "primary", Field: "DaysAccrued", Stored: "0", Derived: "2"},
+			}
+			Expect(myTeam.Reconcile(false)).To(Equal(expected))
+			Expect(myTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(7.0))
+
+			Expect(myTeam.Reconcile(true)).To(Equal(expected))
+			Expect(myTeam.HistoryOfIndividual("person1")).To(Equal(rota.IndividualHistory{Name: "person1", DaysAccrued: 2, LatestPickedDay: Yesterday(), Weight: 1, State: rota.StateActive}))
+			Expect(myTeam.Reconcile(false)).To(BeEmpty())
+		})
+
+		It("New members are seeded with what they joined with", func() {
+			Expect(myTeam.Add("fourth person")).To(Succeed())
+			Expect(myTeam.Reconcile(false)).To(BeEmpty())
+		})
+
+		It("Members who joined before seeds were kept keep their accrued days, with the seed derived once", func() {
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, -2)), []byte("person1"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(9))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(DaysBeforeToday(2)))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.SeedKey("person2"), Float64ToBytes(0))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.SeedKey("third person"), Float64ToBytes(0))).To(Succeed())
+
+			Expect(myTeam.Reconcile(true)).To(BeEmpty())
+			Expect(myTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(9.0))
+			Expect(dbHandle.Read(myTeam.SeedKey("person1"))).To(Equal(Float64ToBytes(8)))
+
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(3))).To(Succeed())
+			Expect(myTeam.Reconcile(false)).To(Equal([]rota.Discrepancy{{Member: "person1", Role: "primary", Field: "DaysAccrued", Stored: "3", Derived: "9"}}))
+		})
+
+		It("Members who joined before seeds were kept with every day covered in the ledger have their drift listed", func() {
+			Expect(dbHandle.Write(myTeam.SeedKey("person2"), Float64ToBytes(0))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.SeedKey("third person"), Float64ToBytes(0))).To(Succeed())
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(6))).To(Succeed())
+
+			expected := []rota.Discrepancy{{Member: "person1", Role: "primary", Field: "DaysAccrued", Stored: "6", Derived: "1"}}
+			Expect(myTeam.Reconcile(true)).To(Equal(expected))
+			Expect(myTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(1.0))
+			Expect(dbHandle.Read(myTeam.SeedKey("person1"))).To(Equal(Float64ToBytes(0)))
+			Expect(myTeam.Reconcile(false)).To(BeEmpty())
+		})
+
+		It("Rebuilds the accrued days from what the assignments accrued, whatever the costs are now", func() {
+			today := strings.ToLower(time.Now().Weekday().String())
+			costs, err := rota.NewCosts(map[string]float64{today: 2}, nil, nil)
+			Expect(err).ToNot(HaveOccurred())
+			costedTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Costs: costs})
+			for _, member := range testTeamMembers {
+				Expect(dbHandle.Write(myTeam.SeedKey(member), Float64ToBytes(0))).To(Succeed())
+			}
+
+			Expect(costedTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(costedTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(2.0))
+
+			Expect(costedTeam.Reconcile(false)).To(BeEmpty())
+			Expect(myTeam.Reconcile(false)).To(BeEmpty())
+		})
+	})
+
+	Context("Confirmation deadline", func() {
+		teamWithDeadline := func(onDeadline string) *rota.Team {
+			return rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{ConfirmationDeadline: time.Nanosecond, OnDeadline: onDeadline})
+		}
+
+		BeforeEach(func() {
+			Expect(dbHandle.Remove(myTeam.LatestCronRunKey())).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(1))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Float64ToBytes(3))).To(Succeed())
+		})
+
+		It("Confirms the suggestion once the deadline passes", func() {
+			deadlineTeam := teamWithDeadline(rota.AutoConfirm)
+			Expect(deadlineTeam.AwaitConfirmation(rota.Selection{Roles: []string{"primary"}, Picks: []string{"person2"}})).To(Succeed())
+
+			Expect(deadlineTeam.ExpirePendingPicks("http://rota")).To(HaveLen(1))
+			Expect(deadlineTeam.PersonPickedOnTheDay(time.Now())).To(Equal("person2"))
+			Expect(deadlineTeam.PendingPicks()).To(BeEmpty())
+		})
+
+		It("Moves on to the next eligible member until nobody is left", func() {
+			deadlineTeam := teamWithDeadline(rota.AutoAdvance)
+			Expect(deadlineTeam.AwaitConfirmation(rota.Selection{Roles: []string{"primary"}, Picks: []string{"person1"}})).To(Succeed())
+
+			messages, err := deadlineTeam.ExpirePendingPicks("http://rota")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(messages[0]).To(ContainSubstring("http://rota/teams/test_team/rota/confirm/person2/" + Today()))
+			pending, err := deadlineTeam.PendingPicks()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pending[0].Passed).To(Equal([]string{"person1"}))
+
+			Expect(deadlineTeam.ExpirePendingPicks("http://rota")).To(HaveLen(1))
+			pending, err = deadlineTeam.PendingPicks()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pending[0].Member).To(Equal("third person"))
+
+			messages, err = deadlineTeam.ExpirePendingPicks("http://rota")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(messages[0]).To(ContainSubstring("nobody else left"))
+			Expect(deadlineTeam.PendingPicks()).To(BeEmpty())
+			Expect(deadlineTeam.PersonPickedOnTheDay(time.Now())).To(Equal("UNKNOWN"))
+		})
+
+		It("Drops the suggestion once confirmed by hand", func() {
+			deadlineTeam := teamWithDeadline(rota.AutoAdvance)
+			Expect(deadlineTeam.AwaitConfirmation(rota.Selection{Roles: []string{"primary"}, Picks: []string{"person1"}})).To(Succeed())
+			Expect(deadlineTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+
+			Expect(deadlineTeam.ExpirePendingPicks("http://rota")).To(BeEmpty())
+			Expect(deadlineTeam.PendingPicks()).To(BeEmpty())
+		})
+
+		It("Teams without a deadline wait indefinitely", func() {
+			Expect(myTeam.AwaitConfirmation(rota.Selection{Roles: []string{"primary"}, Picks: []string{"person1"}})).To(Succeed())
+			Expect(myTeam.PendingPicks()).To(BeEmpty())
+		})
+	})
+
+	Context("Declining a suggestion", func() {
+		BeforeEach(func() {
+			Expect(dbHandle.Remove(myTeam.LatestCronRunKey())).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(1))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Float64ToBytes(3))).To(Succeed())
+		})
+
+		It("Records the reason, counts the decline and suggests the next person", func() {
+			Expect(myTeam.Decline("person1", "on call elsewhere", "person1")).To(Equal("person2"))
+
+			selection, err := myTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Picks).To(Equal([]string{"person2"}))
+			Expect(selection.Skipped).To(Equal([]rota.Skip{{Member: "person1", Role: "primary", Reason: "declined today"}}))
+
+			Expect(myTeam.HistoryOfIndividual("person1").Declines).To(Equal(1))
+			declines, err := myTeam.Declines()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(declines[0].Name).To(Equal("person1"))
+
+			ledger, err := myTeam.LedgerOfIndividual("person1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ledger[len(ledger)-1].Action).To(Equal(rota.ActionDecline))
+			Expect(ledger[len(ledger)-1].Reason).To(Equal("on call elsewhere"))
+		})
+
+		It("A confirmed assignment cannot be declined", func() {
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			_, err := myTeam.Decline("person1", "", "person1")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Only members of the team, and only the one suggested while it waits, can decline", func() {
+			_, err := myTeam.Decline("nobody", "", "nobody")
+			Expect(err).To(HaveOccurred())
+			_, err = dbHandle.Read(myTeam.DeclineCounterKey("nobody"))
+			Expect(err).To(HaveOccurred())
+
+			deadlineTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{ConfirmationDeadline: time.Hour})
+			selection, err := deadlineTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deadlineTeam.AwaitConfirmation(selection)).To(Succeed())
+
+			_, err = deadlineTeam.Decline("person2", "", "person2")
+			Expect(err).To(HaveOccurred())
+			Expect(deadlineTeam.Decline("person1", "", "person1")).To(Equal("person2"))
+		})
+
+		It("Without a confirmation deadline only the member the rota picks today can decline", func() {
+			_, err := myTeam.Decline("person2", "", "person2")
+			Expect(err).To(MatchError("person1 is the one suggested as primary today, so only they can decline"))
+			Expect(myTeam.HistoryOfIndividual("person2").Declines).To(BeZero())
+
+			Expect(myTeam.Decline("person1", "", "person1")).To(Equal("person2"))
+			_, err = myTeam.Decline("third person", "", "third person")
+			Expect(err).To(HaveOccurred())
+			Expect(myTeam.Decline("person2", "", "person2")).To(Equal("third person"))
+		})
+	})
+
+	Context("Onboarding new members", func() {
+		BeforeEach(func() {
+			Expect(dbHandle.Remove(myTeam.LatestCronRunKey())).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(1))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Float64ToBytes(6))).To(Succeed())
+		})
+
+		It("Seeds the accrued days as configured", func() {
+			meanTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Onboarding: rota.Onboarding{Seed: rota.SeedMean}})
+			Expect(meanTeam.Add("newcomer")).To(Succeed())
+			Expect(meanTeam.HistoryOfIndividual("newcomer").DaysAccrued).To(Equal(3.0))
+
+			medianTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Onboarding: rota.Onboarding{Seed: rota.SeedMedian}})
+			Expect(medianTeam.Add("another newcomer")).To(Succeed())
+			Expect(medianTeam.HistoryOfIndividual("another-newcomer").DaysAccrued).To(Equal(2.5))
+		})
+
+		It("Seeds the very first member with nothing when the team seeds from zero", func() {
+			newTeam := rota.NewTeamWithSettings("test_team", localdb.NewMemoryStore(), rota.Settings{Onboarding: rota.Onboarding{Seed: rota.SeedZero}})
+			Expect(newTeam.Add("newcomer")).To(Succeed())
+			Expect(newTeam.HistoryOfIndividual("newcomer").DaysAccrued).To(BeZero())
+
+			defaultTeam := rota.NewTeam("test_team", localdb.NewMemoryStore())
+			Expect(defaultTeam.Add("newcomer")).To(Succeed())
+			Expect(defaultTeam.HistoryOfIndividual("newcomer").DaysAccrued).To(Equal(1.0))
+		})
+
+		It("Leaves the newcomer out of the picks until their grace cycles are filled by others", func() {
+			graceTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Onboarding: rota.Onboarding{Seed: rota.SeedZero, GraceCycles: 1}})
+			Expect(graceTeam.Add("newcomer")).To(Succeed())
+			Expect(graceTeam.HistoryOfIndividual("newcomer").Grace).To(Equal("1 cycles left"))
+
+			selection, err := graceTeam.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(selection.Picks).To(Equal([]string{"person1"}))
+			Expect(selection.Skipped).To(Equal([]rota.Skip{{Member: "newcomer", Role: "primary", Reason: "onboarding grace, 1 cycles left"}}))
+		})
+
+		It("Counts a shift covering several days as a single cycle", func() {
+			graceTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{ShiftDays: 3, Onboarding: rota.Onboarding{GraceCycles: 2}})
+			Expect(graceTeam.Add("newcomer")).To(Succeed())
+			Expect(dbHandle.Write(myTeam.GraceKey("newcomer"), []byte(`{"Joined":"`+DaysBeforeToday(6)+`","Cycles":2}`))).To(Succeed())
+			for day := -6; day <= -4; day++ {
+				Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, day)), []byte("person1"))).To(Succeed())
+			}
+			Expect(graceTeam.HistoryOfIndividual("newcomer").Grace).To(Equal("1 cycles left"))
+
+			for day := -3; day <= -1; day++ {
+				Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(time.Now().AddDate(0, 0, day)), []byte("person2"))).To(Succeed())
+			}
+			Expect(graceTeam.HistoryOfIndividual("newcomer").Grace).To(BeEmpty())
+		})
+
+		It("Gives no grace to archived members coming back", func() {
+			graceTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{Onboarding: rota.Onboarding{GraceCycles: 2}})
+			Expect(graceTeam.Archive("person2")).To(Succeed())
+			Expect(graceTeam.Add("person2")).To(Succeed())
+
+			Expect(graceTeam.HistoryOfIndividual("person2").Grace).To(BeEmpty())
+			_, err := dbHandle.Read(myTeam.GraceKey("person2"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Leaves the newcomer out of the picks until the date they are eligible from", func() {
+			eligibleFrom := time.Now().AddDate(0, 0, 7)
+			Expect(myTeam.AddEligibleFrom("newcomer", eligibleFrom)).To(Succeed())
+			Expect(myTeam.HistoryOfIndividual("newcomer").Grace).To(Equal("eligible from " + eligibleFrom.Format("02-01-2006")))
+		})
+	})
+
+	Context("Swapping shifts", func() {
+		tomorrow := time.Now().AddDate(0, 0, 1)
+
+		BeforeEach(func() {
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person1"), []byte(DaysBeforeToday(14)))).To(Succeed())
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(tomorrow), []byte("person2"))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(3))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.LatestDayPickedKey("person2"), []byte(DaysBeforeToday(5)))).To(Succeed())
+		})
+
+		It("Accepting a swap trades the days along with the accrued and latest picked days", func() {
+			swapRequest, err := myTeam.RequestSwap("primary", "person1", time.Now(), "person2", tomorrow, "person1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(myTeam.PersonPickedOnTheDay(time.Now())).To(Equal("person1"))
+
+			accepted, err := myTeam.AcceptSwap(swapRequest.ID, "person2")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(accepted.Status).To(Equal(rota.SwapAccepted))
+
+			Expect(myTeam.PersonPickedOnTheDay(time.Now())).To(Equal("person2"))
+			Expect(myTeam.PersonPickedOnTheDay(tomorrow)).To(Equal("person1"))
+			Expect(myTeam.HistoryOfIndividual("person1")).To(Equal(rota.IndividualHistory{Name: "person1", DaysAccrued: 1, LatestPickedDay: tomorrow.Format("02-01-2006"), Weight: 1, State: rota.StateActive}))
+			Expect(myTeam.HistoryOfIndividual("person2")).To(Equal(rota.IndividualHistory{Name: "person2", DaysAccrued: 3, LatestPickedDay: Today(), Weight: 1, State: rota.StateActive}))
+
+			ledger, err := myTeam.Ledger()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ledger).To(HaveLen(3))
+			Expect(ledger[1].Action).To(Equal(rota.ActionSwap))
+			Expect(ledger[1].Member).To(Equal("person2"))
+			Expect(ledger[1].PreviousValue).To(Equal("person1"))
+			Expect(ledger[2].Member).To(Equal("person1"))
+			Expect(ledger[2].Date).To(Equal(tomorrow.Format("02-01-2006")))
+		})
+
+		It("Rejecting a swap leaves the rota as it is", func() {
+			swapRequest, err := myTeam.RequestSwap("primary", "person1", time.Now(), "person2", tomorrow, "person1")
+			Expect(err).ToNot(HaveOccurred())
+
+			rejected, err := myTeam.RejectSwap(swapRequest.ID, "person2")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rejected.Status).To(Equal(rota.SwapRejected))
+			_, err = myTeam.AcceptSwap(swapRequest.ID, "person2")
+			Expect(err).To(HaveOccurred())
+
+			Expect(myTeam.PersonPickedOnTheDay(time.Now())).To(Equal("person1"))
+			Expect(myTeam.PersonPickedOnTheDay(tomorrow)).To(Equal("person2"))
+		})
+
+		It("A shift forecast ahead can be swapped and is assigned to the member taking it over", func() {
+			dayAfterTomorrow := time.Now().AddDate(0, 0, 2)
+			forecastingTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{
+				IsHoliday: func(time.Time) (bool, string) {
+					return false, ""
+				},
+				Cadence: func(run time.Time) time.Time {
+					return run.AddDate(0, 0, 1)
+				},
+			})
+
+			_, err := forecastingTeam.RequestSwap("primary", "person1", time.Now(), "person2", dayAfterTomorrow, "person1")
+			Expect(err).To(MatchError("person2 is not assigned as primary on " + dayAfterTomorrow.Format("02-01-2006")))
+
+			swapRequest, err := forecastingTeam.RequestSwap("primary", "person1", time.Now(), "third person", dayAfterTomorrow, "person1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(forecastingTeam.PersonPickedOnTheDay(dayAfterTomorrow)).To(Equal("UNKNOWN"))
+
+			_, err = forecastingTeam.AcceptSwap(swapRequest.ID, "third person")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(forecastingTeam.PersonPickedOnTheDay(time.Now())).To(Equal("third person"))
+			Expect(forecastingTeam.PersonPickedOnTheDay(dayAfterTomorrow)).To(Equal("person1"))
+			Expect(forecastingTeam.HistoryOfIndividual("person1")).To(Equal(rota.IndividualHistory{Name: "person1", DaysAccrued: 1, LatestPickedDay: dayAfterTomorrow.Format("02-01-2006"), Weight: 1, State: rota.StateActive}))
+			Expect(forecastingTeam.HistoryOfIndividual("third person")).To(Equal(rota.IndividualHistory{Name: "third person", DaysAccrued: 1, LatestPickedDay: Today(), Weight: 1, State: rota.StateActive}))
+
+			swapRequests, err := forecastingTeam.SwapRequests()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(swapRequests).To(HaveLen(1))
+			Expect(swapRequests[0].Status).To(Equal(rota.SwapAccepted))
+		})
+
+		It("Only dates the members are assigned on can be swapped", func() {
+			_, err := myTeam.RequestSwap("primary", "person1", time.Now(), "third person", tomorrow, "person1")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Forecasting the rota", func() {
+		It("Projects the picks ahead without changing the rota", func() {
+			everyDayTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{
+				IsHoliday: func(time.Time) (bool, string) {
+					return false, ""
+				},
+			})
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person1"), Float64ToBytes(1))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("person2"), Float64ToBytes(2))).To(Succeed())
+			Expect(dbHandle.Write(myTeam.AccruedDaysCounterKey("third person"), Float64ToBytes(3))).To(Succeed())
+			Expect(dbHandle.Remove(myTeam.LatestCronRunKey())).To(Succeed())
+
+			slots, err := everyDayTeam.Forecast(4, func(run time.Time) time.Time {
+				return run.AddDate(0, 0, 1)
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			picks := make([]string, 0, len(slots))
+			for _, slot := range slots {
+				picks = append(picks, slot.Picks...)
+			}
+			Expect(picks).To(Equal([]string{"person1", "person2", "third person", "person1"}))
+			Expect(slots[0].Date).To(Equal(time.Now().AddDate(0, 0, 1).Format("02-01-2006")))
+
+			Expect(everyDayTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(1.0))
+			Expect(everyDayTeam.PersonPickedOnTheDay(time.Now().AddDate(0, 0, 1))).To(Equal("UNKNOWN"))
+			Expect(everyDayTeam.Ledger()).To(BeEmpty())
+		})
+
+		It("Keeps the picks already confirmed and skips holidays", func() {
+			nextWeekday := time.Now().AddDate(0, 0, 1)
+			for nextWeekday.Weekday() == time.Saturday || nextWeekday.Weekday() == time.Sunday {
+				nextWeekday = nextWeekday.AddDate(0, 0, 1)
+			}
+			Expect(dbHandle.Write(myTeam.PersonPickedOnDayKey(nextWeekday), []byte("person2"))).To(Succeed())
+
+			slots, err := myTeam.Forecast(3, func(run time.Time) time.Time {
+				return run.AddDate(0, 0, 1)
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(slots[0]).To(Equal(rota.ForecastSlot{Date: nextWeekday.Format("02-01-2006"), Roles: []string{"primary"}, Picks: []string{"person2"}, Assigned: true}))
+			for _, slot := range slots {
+				slotDay, err := time.Parse("02-01-2006", slot.Date)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(slotDay.Weekday()).ToNot(Or(Equal(time.Saturday), Equal(time.Sunday)))
+			}
+		})
+
+		It("Leaves the days of a projected multi day shift unassigned", func() {
+			shiftTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{
+				ShiftDays: 3,
+				IsHoliday: func(time.Time) (bool, string) {
+					return false, ""
+				},
+			})
+			Expect(dbHandle.Remove(myTeam.LatestCronRunKey())).To(Succeed())
+
+			slots, err := shiftTeam.Forecast(4, func(run time.Time) time.Time {
+				return run.AddDate(0, 0, 1)
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(slots[1].Picks).To(Equal(slots[0].Picks))
+			Expect(slots[2].Picks).To(Equal(slots[0].Picks))
+			Expect(slots[3].Picks).ToNot(Equal(slots[0].Picks))
+			for _, slot := range slots {
+				Expect(slot.Assigned).To(BeFalse())
+			}
+		})
+	})
+
+	Context("Reporting the roles unconfirmed today", func() {
+		It("Flags the roles nobody is confirmed for on a working day", func() {
+			workingDayTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{
+				Roles: []string{"primary", "secondary"},
+				IsHoliday: func(time.Time) (bool, string) {
+					return false, ""
+				},
+			})
+			Expect(workingDayTeam.UnconfirmedToday()).To(Equal(map[string]bool{"primary": true, "secondary": true}))
+
+			Expect(workingDayTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(workingDayTeam.UnconfirmedToday()).To(Equal(map[string]bool{"primary": false, "secondary": true}))
+		})
+
+		It("Leaves nothing unconfirmed on a holiday", func() {
+			holidayTeam := rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{
+				IsHoliday: func(time.Time) (bool, string) {
+					return true, "Boxing Day"
+				},
+			})
+			Expect(holidayTeam.UnconfirmedToday()).To(Equal(map[string]bool{"primary": false}))
+		})
+	})
+
+	Context("Simulating the rota", func() {
+		daily := func(run time.Time) time.Time {
+			return run.AddDate(0, 0, 1)
+		}
+
+		It("Spreads the days evenly across the team without touching the database", func() {
+			report, err := myTeam.Simulate(daily, rota.SimulationOptions{Start: time.Now(), Days: 90, Seed: 1})
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(report.Members).To(HaveLen(3))
+			Expect(report.Runs).To(BeNumerically(">", 50))
+			Expect(report.Unfilled).To(BeZero())
+			Expect(report.Spread).To(BeNumerically("<=", 1))
+			Expect(report.WorstStarvation).To(BeNumerically("<=", 2))
+
+			ledger, err := myTeam.Ledger()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ledger).To(BeEmpty())
+		})
+
+		It("Leaves the metrics of the live team untouched", func() {
+			confirms := func() []string {
+				recorder := httptest.NewRecorder()
+				promhttp.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+				body, _ := ioutil.ReadAll(recorder.Body)
+				lines := make([]string, 0)
+				for _, line := range strings.Split(string(body), "\n") {
+					if strings.HasPrefix(line, "rota_confirms_total{") {
+						lines = append(lines, line)
+					}
+				}
+				return lines
+			}
+
+			before := confirms()
+			_, err := myTeam.Simulate(daily, rota.SimulationOptions{Start: time.Now(), Days: 30, Seed: 1})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(confirms()).To(Equal(before))
+
+			Expect(myTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(confirms()).ToNot(Equal(before))
+		})
+
+		It("Reports members passed over while others are away", func() {
+			report, err := myTeam.Simulate(daily, rota.SimulationOptions{Start: time.Now(), Days: 90, AbsenceRate: 0.2, MaxAbsenceDays: 5, Seed: 7})
+			Expect(err).ToNot(HaveOccurred())
+
+			picks := 0
+			for _, member := range report.Members {
+				picks += member.Picks
+				Expect(member.DaysAway).To(BeNumerically(">", 0))
+			}
+			Expect(picks + report.Unfilled).To(Equal(report.Runs))
+		})
+
+		It("Simulates another strategy than the team's when asked to", func() {
+			report, err := myTeam.Simulate(daily, rota.SimulationOptions{Start: time.Now(), Days: 30, Seed: 1, Strategy: rota.RoundRobin})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Unfilled).To(BeZero())
+
+			_, err = myTeam.Simulate(daily, rota.SimulationOptions{Start: time.Now(), Days: 30, Seed: 1, Strategy: "alphabetical"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Counts the gap from the start to the first pick and from the last pick to the end", func() {
+			// Everyone goes away on the first day and stays away, so nobody is ever picked
+			report, err := myTeam.Simulate(daily, rota.SimulationOptions{Start: time.Now(), Days: 30, AbsenceRate: 1, MaxAbsenceDays: 60, Seed: 1})
 			Expect(err).ToNot(HaveOccurred())
-			Expect(nextPerson).To(Equal("person2"))
+
+			Expect(report.Unfilled).To(Equal(report.Runs))
+			Expect(report.LongestGap).To(Equal(30))
+			for _, member := range report.Members {
+				Expect(member.Picks).To(BeZero())
+				Expect(member.LongestGap).To(Equal(30))
+			}
+		})
+	})
+
+	Context("Costs of the days covered", func() {
+		teamWithCosts := func(weekdays map[string]float64, preHoliday *float64, dates map[string]float64) *rota.Team {
+			costs, err := rota.NewCosts(weekdays, preHoliday, dates)
+			Expect(err).ToNot(HaveOccurred())
+
+			nextWeekday := time.Now().AddDate(0, 0, 1)
+			for nextWeekday.Weekday() == time.Saturday || nextWeekday.Weekday() == time.Sunday {
+				nextWeekday = nextWeekday.AddDate(0, 0, 1)
+			}
+			return rota.NewTeamWithSettings("test_team", dbHandle, rota.Settings{
+				Costs: costs,
+				IsHoliday: func(day time.Time) (bool, string) {
+					if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+						return true, "Weekend"
+					}
+					return day.Format("02-01-2006") == nextWeekday.Format("02-01-2006"), "Bank holiday"
+				},
+			})
+		}
+
+		It("Confirming accrues the cost of the day of the week", func() {
+			costedTeam := teamWithCosts(map[string]float64{strings.ToLower(time.Now().Weekday().String()): 1.5}, nil, nil)
+
+			Expect(costedTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(costedTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(1.5))
+
+			Expect(costedTeam.OverridePersonPickedForToday("person2", "tester")).To(Succeed())
+			Expect(costedTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(0.0))
+			Expect(costedTeam.HistoryOfIndividual("person2").DaysAccrued).To(Equal(1.5))
+		})
+
+		It("The day before a bank holiday costs more than the day of the week", func() {
+			preHoliday := 2.0
+			costedTeam := teamWithCosts(map[string]float64{time.Now().Weekday().String(): 1.5}, &preHoliday, nil)
+
+			Expect(costedTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(costedTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(2.0))
+		})
+
+		It("Named dates take precedence", func() {
+			preHoliday := 2.0
+			costedTeam := teamWithCosts(nil, &preHoliday, map[string]float64{Today(): 3})
+
+			Expect(costedTeam.SetPersonPickedForToday("person1", "tester")).To(Succeed())
+			Expect(costedTeam.HistoryOfIndividual("person1").DaysAccrued).To(Equal(3.0))
+		})
+
+		It("Invalid cost tables are rejected", func() {
+			_, err := rota.NewCosts(map[string]float64{"funday": 1}, nil, nil)
+			Expect(err).To(HaveOccurred())
+			_, err = rota.NewCosts(nil, nil, map[string]float64{"2026-12-25": 2})
+			Expect(err).To(HaveOccurred())
+			_, err = rota.NewCosts(map[string]float64{"monday": -1}, nil, nil)
+			Expect(err).To(HaveOccurred())
 		})
 	})
 
@@ -186,7 +1542,7 @@ var _ = Describe("Test suite for logic of picking next", func() {
 			Expect(dbHandle.Write(oooFrom, timeToBytes(time.Now().Add(-time.Hour*24))))
 			Expect(dbHandle.Write(oooTo, timeToBytes(time.Now().Add(time.Hour*24))))
 
-			Expect(myTeam.Next()).To(Equal("person1"))
+			Expect(NextPicks(myTeam)).To(Equal([]string{"person1"}))
 		})
 
 		It("Skip the selected person who is off for the day", func() {
@@ -203,11 +1559,17 @@ var _ = Describe("Test suite for logic of picking next", func() {
 			Expect(dbHandle.Write(oooFrom, timeToBytes(time.Now())))
 			Expect(dbHandle.Write(oooTo, timeToBytes(time.Now())))
 
-			Expect(myTeam.Next()).To(Equal("person1"))
+			Expect(NextPicks(myTeam)).To(Equal([]string{"person1"}))
 		})
 	})
 })
 
+func NextPicks(team *rota.Team) []string {
+	selection, err := team.Next()
+	Expect(err).ToNot(HaveOccurred())
+	return selection.Picks
+}
+
 func Today() string {
 	return time.Now().Format("02-01-2006")
 }
@@ -228,6 +1590,12 @@ func timeToBytes(t time.Time) []byte {
 	return []byte(t.Format("02-01-2006"))
 }
 
+func Float64ToBytes(floatVal float64) []byte {
+	byteVal := make([]byte, 8)
+	binary.BigEndian.PutUint64(byteVal, math.Float64bits(floatVal))
+	return byteVal
+}
+
 func Uint16ToBytes(intVal uint16) []byte {
 	byteVal := make([]byte, 2)
 	binary.BigEndian.PutUint16(byteVal, intVal)
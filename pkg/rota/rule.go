@@ -0,0 +1,172 @@
+package rota
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	NotConsecutive = "not_consecutive"
+	NotTogether    = "not_together"
+	NotSameWeek    = "not_same_week"
+)
+
+// previousSlotLookBack is how far back the previous slot is looked for, to cover teams that pick less often than daily
+const previousSlotLookBack = 31
+
+// Rule limits which members can be picked together or one after another
+type Rule interface {
+	// Excludes gives the reason the candidate cannot be picked alongside those already picked for the slot, or empty if they can be
+	Excludes(t Team, candidate string, picked []string) string
+	String() string
+}
+
+// NewRule returns the built in rule by name, applied to the members listed and to anyone with the tag
+func NewRule(name string, members []string, tag string) (Rule, error) {
+	if len(members) == 0 && tag == "" {
+		return nil, fmt.Errorf("rule %s needs members or a tag to apply to", name)
+	}
+
+	group := memberGroup{members, tag}
+	switch name {
+	case NotConsecutive:
+		return notConsecutive{group}, nil
+	case NotTogether:
+		return notTogether{group}, nil
+	case NotSameWeek:
+		return notSameWeek{group}, nil
+	default:
+		return nil, fmt.Errorf("unknown rota rule %q", name)
+	}
+}
+
+// memberGroup is who a rule applies to
+type memberGroup struct {
+	members []string
+	tag     string
+}
+
+// contains tells whether the rule applies to the member. Members can be listed by their ID or by any name making it,
+// so that rules keep applying to members once they are migrated to IDs
+func (g memberGroup) contains(t Team, member string) bool {
+	for _, listed := range g.members {
+		if listed == member || MemberID(listed) == MemberID(member) {
+			return true
+		}
+	}
+	if g.tag == "" {
+		return false
+	}
+
+	tags, _ := t.Tags(member)
+	return contains(tags, g.tag)
+}
+
+// other finds someone else in the group amongst the members
+func (g memberGroup) other(t Team, candidate string, members []string) (string, bool) {
+	for _, member := range members {
+		if member != candidate && g.contains(t, member) {
+			return member, true
+		}
+	}
+	return "", false
+}
+
+func (g memberGroup) String() string {
+	applies := append([]string{}, g.members...)
+	if g.tag != "" {
+		applies = append(applies, "tag "+g.tag)
+	}
+	return strings.Join(applies, ", ")
+}
+
+// notConsecutive stops members of the group covering a slot straight after another member of the group
+type notConsecutive struct {
+	memberGroup
+}
+
+func (r notConsecutive) Excludes(t Team, candidate string, _ []string) string {
+	if !r.contains(t, candidate) {
+		return ""
+	}
+
+	day, previous := t.previousSlot()
+	if member, found := r.other(t, candidate, previous); found {
+		return fmt.Sprintf("%s covered the previous slot on %s", member, day.Format("02-01-2006"))
+	}
+	return ""
+}
+
+func (r notConsecutive) String() string {
+	return fmt.Sprintf("%s(%s)", NotConsecutive, r.memberGroup)
+}
+
+// notTogether stops members of the group covering the same slot in different roles
+type notTogether struct {
+	memberGroup
+}
+
+func (r notTogether) Excludes(t Team, candidate string, picked []string) string {
+	if !r.contains(t, candidate) {
+		return ""
+	}
+
+	if member, found := r.other(t, candidate, picked); found {
+		return fmt.Sprintf("%s is already picked for the slot", member)
+	}
+	return ""
+}
+
+func (r notTogether) String() string {
+	return fmt.Sprintf("%s(%s)", NotTogether, r.memberGroup)
+}
+
+// notSameWeek stops members of the group being picked in the same week, Monday to Sunday
+type notSameWeek struct {
+	memberGroup
+}
+
+func (r notSameWeek) Excludes(t Team, candidate string, picked []string) string {
+	if !r.contains(t, candidate) {
+		return ""
+	}
+
+	if member, found := r.other(t, candidate, picked); found {
+		return fmt.Sprintf("%s is already picked for the slot", member)
+	}
+
+	monday := t.now().AddDate(0, 0, -((int(t.now().Weekday()) + 6) % 7))
+	for _, day := range shiftDates(monday, 7) {
+		if member, found := r.other(t, candidate, t.assignedOn(day)); found {
+			return fmt.Sprintf("%s is on the rota on %s", member, day.Format("02-01-2006"))
+		}
+	}
+	return ""
+}
+
+func (r notSameWeek) String() string {
+	return fmt.Sprintf("%s(%s)", NotSameWeek, r.memberGroup)
+}
+
+// previousSlot finds the latest day before today that someone was assigned on, along with who was assigned
+func (t Team) previousSlot() (time.Time, []string) {
+	for daysBack := 1; daysBack <= previousSlotLookBack; daysBack++ {
+		day := t.now().AddDate(0, 0, -daysBack)
+		if assigned := t.assignedOn(day); len(assigned) > 0 {
+			return day, assigned
+		}
+	}
+	return time.Time{}, nil
+}
+
+// assignedOn lists who is assigned on the day, whatever their role
+func (t Team) assignedOn(day time.Time) []string {
+	assigned := make([]string, 0, len(t.roles))
+	for _, role := range t.roles {
+		if member, err := t.db.Read(t.pickedOnDayKey(day, role)); err == nil {
+			assigned = append(assigned, string(member))
+		}
+	}
+	return assigned
+}
@@ -0,0 +1,234 @@
+package rota
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/supreethrao/automated-rota-manager/pkg/localdb"
+	"gopkg.in/yaml.v2"
+)
+
+// SimulationOptions shape the simulated period and the out of office generated for it.
+// Every working day, each member who is in the office starts an absence with the chance given by AbsenceRate,
+// lasting between 1 and MaxAbsenceDays days. Strategy names a strategy to simulate instead of the team's own.
+type SimulationOptions struct {
+	Start          time.Time
+	Days           int
+	AbsenceRate    float64
+	MaxAbsenceDays int
+	Seed           int64
+	Strategy       string
+}
+
+// SimulatedMember is how one member fared over the simulation.
+// LongestGap is the most days between two of their picks, counting from the start of the simulation to their first pick and from their last
+// pick to the end, so that a member who is never picked has a gap of the whole period. Starvation is the most picks in a row that went to
+// others while they were in the office.
+type SimulatedMember struct {
+	Name        string
+	Picks       int
+	DaysAccrued float64
+	DaysAway    int
+	LongestGap  int
+	Starvation  int
+}
+
+// SimulationReport sums up the simulation. Spread is the difference between the most and the fewest days accrued,
+// and Unfilled counts the roles nobody could be picked for.
+type SimulationReport struct {
+	Runs             int
+	Unfilled         int
+	Spread           float64
+	LongestGap       int
+	LongestGapMember string
+	WorstStarvation  int
+	StarvedMember    string
+	Members          []SimulatedMember
+}
+
+// Simulate runs the team's rota for the simulated period at the times given by nextRun, confirming every pick, against a store held in memory.
+// The members start from nothing with the weights and tags they have now, so that the team's settings can be compared on an even footing.
+// Nothing is written to the team's database.
+func (t Team) Simulate(nextRun func(time.Time) time.Time, options SimulationOptions) (SimulationReport, error) {
+	members, err := t.List()
+	if err != nil {
+		return SimulationReport{}, err
+	}
+	if options.MaxAbsenceDays < 1 {
+		options.MaxAbsenceDays = 1
+	}
+
+	run := options.Start
+	simulation := t
+	simulation.db = localdb.NewMemoryStore()
+	if options.Strategy != "" {
+		if simulation.strategy, err = NewStrategy(options.Strategy, t.tieBreakSeed); err != nil {
+			return SimulationReport{}, err
+		}
+	}
+	simulation.clock = func() time.Time {
+		return run
+	}
+	if err := simulation.copyMembers(t, members); err != nil {
+		return SimulationReport{}, err
+	}
+
+	random := rand.New(rand.NewSource(options.Seed))
+	report := SimulationReport{}
+	tally := make(map[string]*SimulatedMember)
+	lastPicked := make(map[string]time.Time)
+	passedOver := make(map[string]int)
+	awayUntil := make(map[string]time.Time)
+	for _, member := range members {
+		tally[member] = &SimulatedMember{Name: member}
+		lastPicked[member] = options.Start
+	}
+
+	end := options.Start.AddDate(0, 0, options.Days)
+	for run = nextRun(run); run.Before(end); run = nextRun(run) {
+		if isHoliday, _ := t.isHoliday(run); isHoliday {
+			continue
+		}
+
+		for _, member := range members {
+			if !run.Before(awayUntil[member]) && random.Float64() < options.AbsenceRate {
+				days := 1 + random.Intn(options.MaxAbsenceDays)
+				awayUntil[member] = run.AddDate(0, 0, days)
+				tally[member].DaysAway += days
+				if err := simulation.SetOutOfOffice(member, run, awayUntil[member].AddDate(0, 0, -1)); err != nil {
+					return report, err
+				}
+			}
+		}
+
+		picked, unfilled, ran, err := simulation.simulateRun()
+		if err != nil {
+			return report, err
+		}
+		if !ran {
+			// Still covered by a multi day shift
+			continue
+		}
+		report.Runs++
+		report.Unfilled += unfilled
+
+		for _, member := range members {
+			switch {
+			case contains(picked, member):
+				tally[member].gapUntil(lastPicked[member], run)
+				lastPicked[member] = run
+				tally[member].Picks++
+				passedOver[member] = 0
+			case run.Before(awayUntil[member]):
+				passedOver[member] = 0
+			default:
+				passedOver[member]++
+				if passedOver[member] > tally[member].Starvation {
+					tally[member].Starvation = passedOver[member]
+				}
+			}
+		}
+	}
+
+	for _, member := range members {
+		tally[member].gapUntil(lastPicked[member], end)
+	}
+
+	history, err := simulation.RotaHistory()
+	if err != nil {
+		return report, err
+	}
+	fewest, most := math.MaxFloat64, 0.0
+	for _, individual := range history {
+		tally[individual.Name].DaysAccrued = individual.DaysAccrued
+		fewest, most = math.Min(fewest, individual.DaysAccrued), math.Max(most, individual.DaysAccrued)
+	}
+	if len(history) > 0 {
+		report.Spread = most - fewest
+	}
+
+	for _, member := range members {
+		simulated := *tally[member]
+		report.Members = append(report.Members, simulated)
+		if simulated.LongestGap > report.LongestGap {
+			report.LongestGap, report.LongestGapMember = simulated.LongestGap, member
+		}
+		if simulated.Starvation > report.WorstStarvation {
+			report.WorstStarvation, report.StarvedMember = simulated.Starvation, member
+		}
+	}
+	sort.SliceStable(report.Members, func(i, j int) bool {
+		return report.Members[i].DaysAccrued > report.Members[j].DaysAccrued
+	})
+	return report, nil
+}
+
+// gapUntil keeps the gap between the two days if it is the longest yet
+func (m *SimulatedMember) gapUntil(from, to time.Time) {
+	if gap := int(math.Round(to.Sub(from).Hours() / 24)); gap > m.LongestGap {
+		m.LongestGap = gap
+	}
+}
+
+// simulateRun picks and confirms the next person for every role not already covered today.
+// It returns who was picked and the number of roles nobody could be picked for, or false when every role was already covered.
+func (t Team) simulateRun() ([]string, int, bool, error) {
+	uncovered := make([]bool, len(t.roles))
+	ran := false
+	for ind, role := range t.roles {
+		if _, err := t.db.Read(t.pickedOnDayKey(t.now(), role)); err != nil {
+			uncovered[ind], ran = true, true
+		}
+	}
+	if !ran {
+		return nil, 0, false, nil
+	}
+
+	selection, err := t.Next()
+	if err != nil {
+		return nil, 0, true, err
+	}
+
+	picked := make([]string, 0, len(t.roles))
+	unfilled := 0
+	for ind, role := range t.roles {
+		if !uncovered[ind] {
+			continue
+		}
+		if strings.HasPrefix(selection.Picks[ind], "UNKNOWN") {
+			unfilled++
+			continue
+		}
+		if err := t.SetPersonPickedForRole(role, selection.Picks[ind], "simulation"); err != nil {
+			return picked, unfilled, true, err
+		}
+		picked = append(picked, selection.Picks[ind])
+	}
+	return picked, unfilled, true, nil
+}
+
+// copyMembers starts the simulation with the members of the live team, along with their weights, tags and profiles
+func (t Team) copyMembers(live Team, members []string) error {
+	if len(members) == 0 {
+		return fmt.Errorf("there are no members to simulate")
+	}
+
+	teamData, err := yaml.Marshal(teamMembers{members})
+	if err != nil {
+		return err
+	}
+
+	rotaKeys := map[string][]byte{t.TeamKey(): teamData}
+	for _, member := range members {
+		for _, key := range []string{t.WeightKey(member), t.TagsKey(member), t.ProfileKey(member)} {
+			if data, err := live.db.Read(key); err == nil {
+				rotaKeys[key] = data
+			}
+		}
+	}
+	return t.db.MultiWrite(rotaKeys)
+}
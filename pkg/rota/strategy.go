@@ -0,0 +1,186 @@
+package rota
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	LeastAccrued        = "least-accrued"
+	RoundRobin          = "round-robin"
+	LeastRecentlyPicked = "least-recently-picked"
+	FairRandom          = "fair-random"
+
+	// DefaultCooldown is the number of other people picked before the same person is picked again
+	DefaultCooldown = 2
+	// maxCooldownDays is how far back the days covered are searched for the people picked recently
+	maxCooldownDays = 366
+)
+
+// Strategy decides who should be considered for the next pick
+type Strategy interface {
+	// Rank orders the team members, most preferred first. Members left out are not eligible for the pick.
+	Rank(t Team, history TeamRotaHistory) (TeamRotaHistory, error)
+}
+
+// NewStrategy returns the built in strategy by name. The seed is only used by the fair random strategy.
+func NewStrategy(name string, seed int64) (Strategy, error) {
+	switch name {
+	case "", LeastAccrued:
+		return leastAccrued{}, nil
+	case RoundRobin:
+		return roundRobin{}, nil
+	case LeastRecentlyPicked:
+		return leastRecentlyPicked{}, nil
+	case FairRandom:
+		return fairRandom{seed}, nil
+	default:
+		return nil, fmt.Errorf("unknown rota strategy %q", name)
+	}
+}
+
+// leastAccrued prefers whoever has accrued the fewest days
+type leastAccrued struct{}
+
+func (leastAccrued) Rank(t Team, history TeamRotaHistory) (TeamRotaHistory, error) {
+	return t.orderedList(history), nil
+}
+
+// roundRobin goes through the team in the order members were added, carrying on after whoever was picked last
+type roundRobin struct{}
+
+func (roundRobin) Rank(_ Team, history TeamRotaHistory) (TeamRotaHistory, error) {
+	lastPicked := -1
+	var lastPickedDay time.Time
+
+	for ind, individual := range history {
+		if individual.LatestPickedDay == "N/A" {
+			continue
+		}
+		pickedDay, err := time.Parse("02-01-2006", individual.LatestPickedDay)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse date string %s - %v", individual.LatestPickedDay, err)
+		}
+		if lastPicked == -1 || pickedDay.After(lastPickedDay) {
+			lastPicked, lastPickedDay = ind, pickedDay
+		}
+	}
+
+	ranked := make(TeamRotaHistory, 0, len(history))
+	for ind := range history {
+		ranked = append(ranked, history[(lastPicked+1+ind)%len(history)])
+	}
+	return ranked, nil
+}
+
+// leastRecentlyPicked prefers whoever has gone the longest without being picked
+type leastRecentlyPicked struct{}
+
+func (leastRecentlyPicked) Rank(t Team, history TeamRotaHistory) (TeamRotaHistory, error) {
+	// Members picked on the same day are left in the usual order
+	ranked := t.orderedList(append(TeamRotaHistory{}, history...))
+	daysSincePicked := make(map[string]int)
+
+	for _, individual := range ranked {
+		pickedDay, err := time.Parse("02-01-2006", latestPickedDay(individual))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse date string %s - %v", individual.LatestPickedDay, err)
+		}
+		daysSincePicked[individual.Name] = int(t.now().Sub(pickedDay).Hours() / 24)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return daysSincePicked[ranked[i].Name] > daysSincePicked[ranked[j].Name]
+	})
+	return ranked, nil
+}
+
+// fairRandom draws members at random, giving those with a lower load a better chance.
+// The draw is seeded with the day so that it is repeatable until the pick is confirmed.
+type fairRandom struct {
+	seed int64
+}
+
+func (f fairRandom) Rank(t Team, history TeamRotaHistory) (TeamRotaHistory, error) {
+	hash := fnv.New64a()
+	_, _ = fmt.Fprintf(hash, "%d:%s", f.seed, t.today())
+	random := rand.New(rand.NewSource(int64(hash.Sum64())))
+
+	mostLoaded := 0.0
+	for _, individual := range history {
+		mostLoaded = math.Max(mostLoaded, individual.Load())
+	}
+
+	remaining := append(TeamRotaHistory{}, history...)
+	ranked := make(TeamRotaHistory, 0, len(history))
+	for len(remaining) > 0 {
+		totalChance := 0.0
+		for _, individual := range remaining {
+			totalChance += mostLoaded - individual.Load() + 1
+		}
+
+		draw := random.Float64() * totalChance
+		for ind, individual := range remaining {
+			draw -= mostLoaded - individual.Load() + 1
+			// The last one takes whatever is left over from rounding
+			if draw < 0 || ind == len(remaining)-1 {
+				ranked = append(ranked, individual)
+				remaining = append(remaining[:ind], remaining[ind+1:]...)
+				break
+			}
+		}
+	}
+	return ranked, nil
+}
+
+// coolingDown lists the members picked too recently to be picked again, walking back through the days covered until enough
+// different people are found. A member cools down until the team's cooldown of other people have been picked after them,
+// which is capped at one fewer than the active members. It applies whatever the strategy, and gives way when nobody else can be picked.
+func (t Team) coolingDown() (map[string]bool, error) {
+	members, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+
+	active := 0
+	for _, member := range members {
+		if state, _ := t.State(member); state == StateActive {
+			active++
+		}
+	}
+	cooldown := t.cooldown
+	if cooldown > active-1 {
+		cooldown = active - 1
+	}
+	logrus.Infof("members cool down until %d others are picked", cooldown)
+
+	// Each member is counted once for the most recent day they covered, in the order of the roles on the day
+	coolingDown := make(map[string]bool)
+	picked := 0
+	for day, daysBack := t.now(), 0; picked < cooldown && daysBack < maxCooldownDays; day, daysBack = day.AddDate(0, 0, -1), daysBack+1 {
+		for _, role := range t.roles {
+			member, err := t.db.Read(t.pickedOnDayKey(day, role))
+			if err != nil || coolingDown[string(member)] || picked == cooldown {
+				continue
+			}
+			coolingDown[string(member)] = true
+			picked++
+		}
+	}
+	return coolingDown, nil
+}
+
+// latestPickedDay treats someone who has never been picked as picked a long time ago
+func latestPickedDay(individual IndividualHistory) string {
+	// If the person is newly added and has not been picked yet, this value will be N/A. Else that person is ripe to be picked next
+	if individual.LatestPickedDay == "N/A" {
+		return "31-12-2006"
+	}
+	return individual.LatestPickedDay
+}
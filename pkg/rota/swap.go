@@ -0,0 +1,279 @@
+package rota
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	SwapPending  = "pending"
+	SwapAccepted = "accepted"
+	SwapRejected = "rejected"
+)
+
+// SwapRequest is a proposal from one member to trade the shift they are assigned on FromDate for the shift the other member is assigned on ToDate
+type SwapRequest struct {
+	ID          uint64
+	Role        string
+	From        string
+	FromDate    string
+	To          string
+	ToDate      string
+	Status      string
+	RequestedBy string
+	RespondedBy string
+	RecordedAt  string
+}
+
+// RequestSwap records the proposal for the other member to accept or reject
+func (t Team) RequestSwap(role, from string, fromDate time.Time, to string, toDate time.Time, actor string) (SwapRequest, error) {
+	if err := t.checkRole(role); err != nil {
+		return SwapRequest{}, err
+	}
+	from, err := t.Resolve(from)
+	if err != nil {
+		return SwapRequest{}, err
+	}
+	if to, err = t.Resolve(to); err != nil {
+		return SwapRequest{}, err
+	}
+	if from == to {
+		return SwapRequest{}, fmt.Errorf("%s cannot swap with themselves", from)
+	}
+
+	request := SwapRequest{
+		Role:        role,
+		From:        from,
+		FromDate:    fromDate.Format("02-01-2006"),
+		To:          to,
+		ToDate:      toDate.Format("02-01-2006"),
+		Status:      SwapPending,
+		RequestedBy: actor,
+		RecordedAt:  t.now().Format(time.RFC3339),
+	}
+	if _, _, err := t.swappableShifts(request); err != nil {
+		return SwapRequest{}, err
+	}
+
+	id, err := t.db.NextSeq(t.SwapSequenceKey())
+	if err != nil {
+		return SwapRequest{}, err
+	}
+	request.ID = id
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return SwapRequest{}, err
+	}
+	return request, t.db.Write(t.SwapRequestKey(id), data)
+}
+
+// SwapRequests lists every swap request made in the team, oldest first
+func (t Team) SwapRequests() ([]SwapRequest, error) {
+	data, err := t.db.ReadWithPrefix(t.SwapRequestPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]SwapRequest, 0, len(data))
+	for key, value := range data {
+		var request SwapRequest
+		if err := json.Unmarshal(value, &request); err != nil {
+			return nil, fmt.Errorf("unable to read swap request %s: %v", key, err)
+		}
+		requests = append(requests, request)
+	}
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].ID < requests[j].ID
+	})
+	return requests, nil
+}
+
+func (t Team) SwapRequest(id uint64) (SwapRequest, error) {
+	var request SwapRequest
+	data, err := t.db.Read(t.SwapRequestKey(id))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return request, fmt.Errorf("no swap request %d", id)
+		}
+		return request, err
+	}
+	return request, json.Unmarshal(data, &request)
+}
+
+// AcceptSwap trades the two shifts. Both members' days, accrued days and latest picked days are updated along with the ledger in a single write.
+// A shift that was only forecast is assigned ahead of time to the member taking it over.
+func (t Team) AcceptSwap(id uint64, actor string) (SwapRequest, error) {
+	request, err := t.pendingSwapRequest(id)
+	if err != nil {
+		return request, err
+	}
+	fromShift, toShift, err := t.swappableShifts(request)
+	if err != nil {
+		return request, err
+	}
+
+	rotaKeys := make(map[string][]byte)
+	if !fromShift.forecast {
+		if _, err := t.unassign(rotaKeys, request.From, request.Role, fromShift.start); err != nil {
+			return request, err
+		}
+	}
+	if !toShift.forecast {
+		if _, err := t.unassign(rotaKeys, request.To, request.Role, toShift.start); err != nil {
+			return request, err
+		}
+	}
+
+	if err := t.checkNotCoveringAnotherRole(request.To, request.Role, fromShift.start, fromShift.days); err != nil {
+		return request, err
+	}
+	if err := t.checkNotCoveringAnotherRole(request.From, request.Role, toShift.start, toShift.days); err != nil {
+		return request, err
+	}
+
+	for _, assignment := range []LedgerEntry{
+		t.assign(rotaKeys, request.To, request.Role, fromShift.start, fromShift.days),
+		t.assign(rotaKeys, request.From, request.Role, toShift.start, toShift.days),
+	} {
+		assignment.Action = ActionSwap
+		assignment.Actor = actor
+		assignment.PreviousValue = request.From
+		if assignment.Member == request.From {
+			assignment.PreviousValue = request.To
+		}
+
+		ledgerKey, ledgerEntry, err := t.ledgerEntry(assignment)
+		if err != nil {
+			return request, err
+		}
+		rotaKeys[ledgerKey] = ledgerEntry
+	}
+
+	request.Status = SwapAccepted
+	request.RespondedBy = actor
+	data, err := json.Marshal(request)
+	if err != nil {
+		return request, err
+	}
+	rotaKeys[t.SwapRequestKey(id)] = data
+
+	return request, t.writeAssignments(rotaKeys, nil)
+}
+
+// RejectSwap closes the request leaving the rota as it is
+func (t Team) RejectSwap(id uint64, actor string) (SwapRequest, error) {
+	request, err := t.pendingSwapRequest(id)
+	if err != nil {
+		return request, err
+	}
+
+	request.Status = SwapRejected
+	request.RespondedBy = actor
+	data, err := json.Marshal(request)
+	if err != nil {
+		return request, err
+	}
+	return request, t.db.Write(t.SwapRequestKey(id), data)
+}
+
+// SwapRequestMessage asks the other member to respond to the request
+func (t Team) SwapRequestMessage(host string, request SwapRequest) string {
+	return fmt.Sprintf("%s would like to swap their shift on %s with %s's shift on %s. \n"+
+		"%s, to accept click: %s \n"+
+		"To reject click: %s \n", request.From, request.FromDate, request.To, request.ToDate, request.To,
+		t.swapURL(host, request, "accept"), t.swapURL(host, request, "reject"))
+}
+
+func (t Team) swapURL(host string, request SwapRequest, response string) string {
+	return fmt.Sprintf("%s/swaps/%d/%s?by=%s", t.teamURL(host), request.ID, response, url.QueryEscape(request.To))
+}
+
+func (t Team) pendingSwapRequest(id uint64) (SwapRequest, error) {
+	request, err := t.SwapRequest(id)
+	if err != nil {
+		return request, err
+	}
+	if request.Status != SwapPending {
+		return request, fmt.Errorf("swap request %d has already been %s", id, request.Status)
+	}
+	return request, nil
+}
+
+// swappableShifts finds the shifts being traded, making sure both members are still assigned or forecast on the dates and neither shift has started
+func (t Team) swappableShifts(request SwapRequest) (shift, shift, error) {
+	today, _ := time.Parse("02-01-2006", t.today())
+
+	shifts := make([]shift, 0, 2)
+	for _, assigned := range [][]string{{request.From, request.FromDate}, {request.To, request.ToDate}} {
+		member, date := assigned[0], assigned[1]
+		day, err := time.Parse("02-01-2006", date)
+		if err != nil {
+			return shift{}, shift{}, fmt.Errorf("unable to parse date string %s - %v", date, err)
+		}
+
+		memberShift, err := t.swappableShift(member, request.Role, day, today)
+		if err != nil {
+			return shift{}, shift{}, err
+		}
+		if memberShift.start.Before(today) {
+			return shift{}, shift{}, fmt.Errorf("%s's shift on %s has already started and cannot be swapped", member, date)
+		}
+		shifts = append(shifts, memberShift)
+	}
+	return shifts[0], shifts[1], nil
+}
+
+// swappableShift finds the member's shift on the date. A later date nobody is assigned on yet is forecast up to, for teams with a cadence
+func (t Team) swappableShift(member, role string, day, today time.Time) (shift, error) {
+	assigned := t.PersonPickedOnTheDayForRole(day, role)
+	if assigned == member {
+		return t.shiftCovering(member, role, day)
+	}
+
+	if assigned == "UNKNOWN" && day.After(today) && t.cadence != nil {
+		simulation, _, err := t.project(t.cadence, func(slots []ForecastSlot) bool {
+			if len(slots) == 0 {
+				return false
+			}
+			latest, err := time.Parse("02-01-2006", slots[len(slots)-1].Date)
+			return err != nil || !latest.Before(day)
+		})
+		if err != nil {
+			return shift{}, err
+		}
+		if simulation.PersonPickedOnTheDayForRole(day, role) == member {
+			forecastShift, err := simulation.shiftCovering(member, role, day)
+			forecastShift.forecast = true
+			return forecastShift, err
+		}
+	}
+	return shift{}, fmt.Errorf("%s is not assigned as %s on %s", member, role, day.Format("02-01-2006"))
+}
+
+// shift is the days a member covers in a row. A forecast shift is only projected, so there is nothing stored to hand back when it is swapped
+type shift struct {
+	start    time.Time
+	days     int
+	forecast bool
+}
+
+// shiftCovering finds the whole shift the member is assigned on the date. Assignments made before the ledger existed cover the date alone
+func (t Team) shiftCovering(member, role string, day time.Time) (shift, error) {
+	assignment, found, err := t.assignmentCovering(member, role, day)
+	if err != nil || !found {
+		return shift{start: day, days: 1}, err
+	}
+
+	start, err := time.Parse("02-01-2006", assignment.Date)
+	if err != nil {
+		return shift{}, err
+	}
+	return shift{start: start, days: assignment.shiftDays()}, nil
+}
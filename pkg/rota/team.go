@@ -6,10 +6,10 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/dgraph-io/badger"
-	"github.com/sirupsen/logrus"
 	"github.com/supreethrao/automated-rota-manager/pkg/keys"
 	"github.com/supreethrao/automated-rota-manager/pkg/localdb"
 
@@ -17,16 +17,63 @@ import (
 )
 
 const (
-	maxUInt16 = 65535
+	defaultWeight = 1.0
 )
 
 // name will be used as the key prefix
 type Team struct {
 	name string
-	db *localdb.LocalDB
+	db localdb.Store
+	strategy Strategy
+	roles []string
+	shiftDays int
+	isHoliday func(time.Time) (bool, string)
+	requirement Requirement
+	rules []Rule
+	costs Costs
+	confirmationDeadline time.Duration
+	onDeadline string
+	onboarding Onboarding
+	cooldown int
+	tieBreakSeed int64
+	explainPicks bool
+	cadence func(time.Time) time.Time
+	clock func() time.Time
 	keys.Keys
 }
 
+// Settings tailor how a team's rota is run
+type Settings struct {
+	// Strategy picks the next person. Defaults to the least accrued strategy
+	Strategy Strategy
+	// Roles filled on every slot, each by a different person. Defaults to a single primary role
+	Roles []string
+	// ShiftDays is the number of days covered by every pick, such as 7 for a weekly shift. Defaults to a single day
+	ShiftDays int
+	// IsHoliday tells the working days apart when accruing multi day shifts. Defaults to weekends only
+	IsHoliday func(time.Time) (bool, string)
+	// Requirement is what members' tags have to satisfy to be picked. Defaults to no requirement
+	Requirement Requirement
+	// Rules limit which members can be picked together or one after another
+	Rules []Rule
+	// Costs weigh what each day of cover accrues. Defaults to every day accruing one
+	Costs Costs
+	// ConfirmationDeadline is how long a suggestion waits to be confirmed. Defaults to waiting for as long as it takes
+	ConfirmationDeadline time.Duration
+	// OnDeadline is what happens when the deadline passes, either AutoConfirm (default) or AutoAdvance to the next eligible member
+	OnDeadline string
+	// Onboarding is how new members join. Defaults to seeding them level with the least loaded member and no grace
+	Onboarding Onboarding
+	// Cooldown is the number of other people picked before the same person is picked again. Defaults to DefaultCooldown
+	Cooldown *int
+	// Seed settles the ties left in the order members are considered for the pick, so that teams can reshuffle them
+	Seed int64
+	// ExplainPicks adds how every member was considered to the Slack message announcing the pick
+	ExplainPicks bool
+	// Cadence gives the time of the run after the one given, so that shifts forecast ahead can be swapped. Defaults to only swapping shifts already assigned
+	Cadence func(time.Time) time.Time
+}
+
 type outofoffice struct {
 	Name        string
 	OutOfOffice string
@@ -36,6 +83,14 @@ type teamMembers struct {
 	Members []string `yaml:"members"`
 }
 
+type memberTags struct {
+	Tags []string `yaml:"tags"`
+}
+
+func (t Team) Name() string {
+	return t.name
+}
+
 func (t Team) List() ([]string, error) {
 	data, err := t.db.Read(t.TeamKey())
 	if err != nil {
@@ -54,15 +109,26 @@ func (t Team) List() ([]string, error) {
 	return members.Members, nil
 }
 
-func (t Team) Add(newMember string) error {
+// Add the member to the rota under the ID made from their name, which is also their display name until their profile is edited
+func (t Team) Add(newMemberName string) error {
+	newMember := MemberID(newMemberName)
+	if state, _ := t.State(newMemberName); state == StateArchived {
+		// Archived before IDs were introduced, so they come back under their name until they are migrated
+		newMember = newMemberName
+	}
+	if newMember == "" {
+		return fmt.Errorf("%q does not make a valid member ID", newMemberName)
+	}
+
 	currentMembers, err := t.List()
 	if err != nil {
 		return err
 	}
 
 	for _, member := range currentMembers {
-		if newMember == member {
-			log.Printf("%s is already a member", newMember)
+		// Members added before IDs were introduced are still known by their name until they are migrated
+		if newMember == member || newMemberName == member {
+			log.Printf("%s is already a member", newMemberName)
 			return nil
 		}
 	}
@@ -70,8 +136,35 @@ func (t Team) Add(newMember string) error {
 	updatedTeam := teamMembers{append(currentMembers, newMember)}
 	if data, err := yaml.Marshal(updatedTeam); err == nil {
 		multiData := map[string][]byte{
-			t.TeamKey():                        data,
-			t.AccruedDaysCounterKey(newMember): uintToBytes(t.lowestAccruedDaysAmongstTeamMembers()),
+			t.TeamKey(): data,
+		}
+		if _, err := t.db.Read(t.ProfileKey(newMember)); err != nil {
+			profile, err := json.Marshal(Profile{ID: newMember, DisplayName: newMemberName})
+			if err != nil {
+				return err
+			}
+			multiData[t.ProfileKey(newMember)] = profile
+		}
+
+		// Accruals are tracked separately for each role, so each role is seeded from its own history
+		if state, _ := t.State(newMember); state == StateArchived {
+			for _, role := range t.roles {
+				t.liftReturningMember(multiData, newMember, role, t.onboardingSeed(role))
+			}
+			return t.db.MultiWriteAndRemove(multiData, []string{t.StateKey(newMember)})
+		}
+
+		// Only members joining for the first time are given grace
+		memberGrace, err := t.newMemberGrace()
+		if err != nil {
+			return err
+		}
+		multiData[t.GraceKey(newMember)] = memberGrace
+
+		for _, role := range t.roles {
+			seed := t.onboardingSeed(role)
+			multiData[t.accruedDaysKey(newMember, role)] = floatToBytes(seed)
+			multiData[t.seedKey(newMember, role)] = floatToBytes(seed)
 		}
 		return t.db.MultiWrite(multiData)
 	} else {
@@ -79,31 +172,26 @@ func (t Team) Add(newMember string) error {
 	}
 }
 
+// Remove archives the member, keeping their history for when they come back
 func (t Team) Remove(existingMember string) error {
-	currentMembers, err := t.List()
-	if err != nil {
-		return err
-	}
-	updatedMembers := make([]string, 0)
-
-	for _, member := range currentMembers {
-		if member != existingMember {
-			updatedMembers = append(updatedMembers, member)
-		}
-	}
-	updatedTeam := teamMembers{updatedMembers}
-	if data, err := yaml.Marshal(updatedTeam); err == nil {
-		return t.db.Write(t.TeamKey(), data)
-	} else {
-		return err
-	}
+	return t.Archive(existingMember)
 }
 
 func (t Team) HistoryOfIndividual(member string) IndividualHistory {
-	history := IndividualHistory{member, 0, "N/A"}
-	count, err := t.db.Read(t.AccruedDaysCounterKey(member))
+	return t.HistoryOfIndividualForRole(member, t.roles[0])
+}
+
+// HistoryOfIndividualForRole reads the days the member has accrued covering the role. Accruals are tracked separately for each role
+func (t Team) HistoryOfIndividualForRole(member string, role string) IndividualHistory {
+	history := IndividualHistory{member, 0, "N/A", defaultWeight, 0, StateActive, "", "", ""}
+	count, err := t.db.Read(t.accruedDaysKey(member, role))
+	if err == nil {
+		history.DaysAccrued = bytesToFloat(count)
+	}
+
+	weight, err := t.db.Read(t.WeightKey(member))
 	if err == nil {
-		history.DaysAccrued = bytesToUint(count)
+		history.Weight = bytesToFloat(weight)
 	}
 
 	day, err := t.db.Read(t.LatestDayPickedKey(member))
@@ -111,22 +199,78 @@ func (t Team) HistoryOfIndividual(member string) IndividualHistory {
 		history.LatestPickedDay = string(day)
 	}
 
+	declines, err := t.db.Read(t.DeclineCounterKey(member))
+	if err == nil {
+		history.Declines = int(bytesToFloat(declines))
+	}
+
+	history.State, history.ResumeOn = t.State(member)
+	history.Grace = t.inGrace(member)
+	history.JoinedOn = t.graceOf(member).Joined
+
 	return history
 }
 
 func (t Team) RotaHistory() (TeamRotaHistory, error) {
+	return t.RotaHistoryForRole(t.roles[0])
+}
+
+func (t Team) RotaHistoryForRole(role string) (TeamRotaHistory, error) {
+	if err := t.checkRole(role); err != nil {
+		return nil, err
+	}
+
 	teamHistory := make([]IndividualHistory, 0)
 	teamList, err := t.List()
 	if err != nil {
 		 return nil, err
 	}
 	for _, member := range teamList {
-		teamHistory = append(teamHistory, t.HistoryOfIndividual(member))
+		teamHistory = append(teamHistory, t.HistoryOfIndividualForRole(member, role))
 	}
 	return teamHistory, nil
 }
 
 
+// SetWeight sets the member's share of the rota. A member with a weight of 0.5 is picked half as often as someone with the default of 1
+func (t Team) SetWeight(memberName string, weight float64) error {
+	if weight <= 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return fmt.Errorf("weight %v has to be a number greater than 0", weight)
+	}
+	return t.db.Write(t.WeightKey(memberName), floatToBytes(weight))
+}
+
+// SetTags replaces the member's tags, such as the skills or access they have
+func (t Team) SetTags(memberName string, tags []string) error {
+	for _, tag := range tags {
+		if tag == "" || strings.IndexFunc(tag, func(r rune) bool { return !isTagRune(r) }) >= 0 {
+			return fmt.Errorf("tag %q can only contain letters, digits, '-', '_' and '.'", tag)
+		}
+	}
+
+	data, err := yaml.Marshal(memberTags{tags})
+	if err != nil {
+		return err
+	}
+	return t.db.Write(t.TagsKey(memberName), data)
+}
+
+func (t Team) Tags(memberName string) ([]string, error) {
+	data, err := t.db.Read(t.TagsKey(memberName))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	tags := memberTags{}
+	if err = yaml.Unmarshal(data, &tags); err != nil {
+		return nil, err
+	}
+	return tags.Tags, nil
+}
+
 func (t Team) SetOutOfOffice(memberName string, from time.Time, to time.Time) error {
 	fromDate := from.Format("02-01-2006")
 	toDate := to.Format("02-01-2006")
@@ -153,7 +297,7 @@ func (t Team) GetTeamOutOfOffice() ([]byte, error) {
 }
 
 func (t Team) IsAvailable(memberName string) bool {
-	today := time.Now().Format("02-01-2006")
+	today := t.now().Format("02-01-2006")
 	fromKey, toKey := t.OutOfOfficeKey(memberName)
 
 	from, errFrom := t.db.Read(fromKey)
@@ -173,53 +317,142 @@ func (t Team) IsAvailable(memberName string) bool {
 	return true
 }
 
-func (t Team) Next() (string, error) {
-	history, err := t.RotaHistory()
+// Next picks a different person for each of the team's roles. Members passed over are listed along with the reason
+func (t Team) Next() (Selection, error) {
+	selection := Selection{Roles: t.Roles()}
+
+	for _, role := range t.roles {
+		nextPerson, skipped, trace, err := t.nextForRole(role, selection.Picks)
+		if err != nil {
+			return selection, err
+		}
+		selection.Picks = append(selection.Picks, nextPerson)
+		selection.Skipped = append(selection.Skipped, skipped...)
+		selection.Trace = append(selection.Trace, trace...)
+	}
+	return selection, nil
+}
+
+// nextForRole picks the member for the role, along with those skipped and how every member was considered
+func (t Team) nextForRole(role string, alreadyPicked []string) (string, []Skip, []Explanation, error) {
+	history, err := t.RotaHistoryForRole(role)
 	if err != nil {
-		return "", err
+		return "", nil, nil, err
 	}
-	teamRotaHistory := orderedList(history)
 
-	if teamRotaHistory.Len() < 1 {
-		return "UNKNOWN-HISTORY", nil
+	if history.Len() < 1 {
+		return "UNKNOWN-HISTORY", nil, nil, nil
 	}
 
-	// Days in between picking same person. Set at 2 times the frequency. i.e same person won't be picked before having picked at least 2 others
-	minDaysInBetween := 0
-	lastRun, err := t.db.Read(t.LatestCronRunKey())
+	candidates, err := t.strategy.Rank(t, history)
 	if err != nil {
-		logrus.Errorf("unable to obtain last run time: %v", err)
-	} else {
-		minDaysInBetween, err = differenceBetweenDays(string(lastRun), today())
+		return "UNKNOWN-ERROR", nil, nil, err
+	}
+
+	coolingDown, err := t.coolingDown()
+	if err != nil {
+		return "UNKNOWN-ERROR", nil, nil, err
+	}
+
+	nextPerson := "UNKNOWN-UNKNOWN"
+	skipped := make([]Skip, 0)
+	trace := make([]Explanation, 0, len(history))
+	ranked := make(map[string]bool)
+	// The first member cooling down who could otherwise be picked, in case nobody else can be
+	fallback := -1
+	for _, individual := range candidates {
+		ranked[individual.Name] = true
+		switch {
+		case !strings.HasPrefix(nextPerson, "UNKNOWN"):
+			trace = append(trace, t.explain(individual, role, coolingDown, DecisionNotReached))
+		case contains(alreadyPicked, individual.Name):
+			trace = append(trace, t.explain(individual, role, coolingDown, DecisionAlreadyPicked))
+		case coolingDown[individual.Name]:
+			if fallback < 0 && t.ineligibility(individual.Name, role, alreadyPicked) == "" {
+				fallback = len(trace)
+			}
+			trace = append(trace, t.explain(individual, role, coolingDown, DecisionCoolingDown))
+		default:
+			if reason := t.ineligibility(individual.Name, role, alreadyPicked); reason != "" {
+				skipped = append(skipped, Skip{individual.Name, role, reason})
+				trace = append(trace, t.explain(individual, role, coolingDown, "skipped: "+reason))
+				continue
+			}
+			nextPerson = individual.Name
+			trace = append(trace, t.explain(individual, role, coolingDown, DecisionPicked))
+		}
+	}
+
+	if strings.HasPrefix(nextPerson, "UNKNOWN") && fallback >= 0 {
+		nextPerson = trace[fallback].Member
+		trace[fallback].Decision = DecisionPicked
+		log.Printf("Nobody else can be picked as %s, so the cooldown gives way to %s", role, nextPerson)
+	}
+
+	for _, individual := range t.orderedList(history) {
+		if ranked[individual.Name] {
+			continue
+		}
+		decision := DecisionLeftOut
+		if coolingDown[individual.Name] {
+			decision = DecisionCoolingDown
+		}
+		trace = append(trace, t.explain(individual, role, coolingDown, decision))
+	}
+	return nextPerson, skipped, trace, nil
+}
+
+// ineligibility is the reason the member cannot be picked for the role today alongside those already picked, or empty if they can be
+func (t Team) ineligibility(memberName string, role string, alreadyPicked []string) string {
+	if contains(t.declinedToday(role), memberName) {
+		return "declined today"
+	}
+
+	if t.requirement != nil {
+		tags, err := t.Tags(memberName)
 		if err != nil {
-			logrus.Errorf("unable to obtain difference in number of days since the cron run. Defaulting to 0: %v", err)
-		} else {
-			// There should be at least 2 different picks before the same person is picked again.
-			minDaysInBetween *= 2
+			return fmt.Sprintf("unable to read tags: %v", err)
+		}
+
+		tagSet := make(map[string]bool)
+		for _, tag := range tags {
+			tagSet[tag] = true
+		}
+		if !t.requirement.Satisfied(tagSet) {
+			return fmt.Sprintf("does not meet the requirement %s", t.requirement)
 		}
 	}
 
-	logrus.Infof("min days in between picks %v", minDaysInBetween)
-	for _, individual := range teamRotaHistory {
-		latestPickedDay := "31-12-2006"
-		// If the person is newly added and has not been picked yet, this value will be N/A. Else that person is ripe to be picked next
-		if individual.LatestPickedDay != "N/A" {
-			latestPickedDay = individual.LatestPickedDay
+	if !t.IsAvailable(memberName) {
+		return "out of office"
+	}
+
+	if state, resumeOn := t.State(memberName); state == StatePaused {
+		if resumeOn != "" {
+			return fmt.Sprintf("paused until %s", resumeOn)
 		}
+		return "paused"
+	}
 
-		if diffBetweenLastPick, err := differenceBetweenDays(latestPickedDay, today()); err == nil {
-			if  diffBetweenLastPick > minDaysInBetween {
-				probablePerson := individual.Name
+	if grace := t.inGrace(memberName); grace != "" {
+		return fmt.Sprintf("onboarding grace, %s", grace)
+	}
 
-				if t.IsAvailable(probablePerson) {
-					return probablePerson, nil
-				}
-			}
-		} else {
-			return "UNKNOWN-ERROR", err
+	for _, rule := range t.rules {
+		if reason := rule.Excludes(t, memberName, alreadyPicked); reason != "" {
+			return fmt.Sprintf("excluded by rule %s as %s", rule, reason)
 		}
 	}
-	return "UNKNOWN-UNKNOWN", nil
+	return ""
+}
+
+func contains(members []string, member string) bool {
+	for _, existing := range members {
+		if existing == member {
+			return true
+		}
+	}
+	return false
 }
 
 func (t Team) outOfOffice(names []string) []byte {
@@ -243,62 +476,80 @@ func (t Team) outOfOffice(names []string) []byte {
 	}
 }
 
-func uintToBytes(val uint16) []byte {
-	bytesVal := make([]byte, 2)
-	binary.BigEndian.PutUint16(bytesVal, val)
+func floatToBytes(val float64) []byte {
+	bytesVal := make([]byte, 8)
+	binary.BigEndian.PutUint64(bytesVal, math.Float64bits(val))
 	return bytesVal
 }
 
-func bytesToUint(val []byte) uint16 {
-	return binary.BigEndian.Uint16(val)
-}
-
-func differenceBetweenDays(ddmmyyyyStr1, ddmmyyyystr2 string) (int, error) {
-	firstDay, e1 := time.Parse("02-01-2006", ddmmyyyyStr1)
-	if e1 != nil {
-		return 0, fmt.Errorf("Unable to parse date string %s - %v", ddmmyyyyStr1, e1)
-	}
-	secondDay, e2 := time.Parse("02-01-2006", ddmmyyyystr2)
-	if e2 != nil {
-		return 0, fmt.Errorf("Unable to parse date string %s - %v", ddmmyyyystr2, e2)
+func bytesToFloat(val []byte) float64 {
+	switch len(val) {
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(val))
+	case 2:
+		// Counters written before fractional accruals were stored as whole numbers
+		return float64(binary.BigEndian.Uint16(val))
+	default:
+		// Counters that have never been written are read as empty
+		return 0
 	}
+}
 
-	// check second day is after the first day
-	if secondDay.After(firstDay) {
-		return int(math.Round(math.Abs(secondDay.Sub(firstDay).Hours() / 24))), nil
+// now is the time the team's rota is evaluated at. It only differs from the wall clock while simulating the rota ahead
+func (t Team) now() time.Time {
+	if t.clock == nil {
+		return time.Now()
 	}
-	return 0, fmt.Errorf("cron run date %v is in the future and this is incorrect. ", secondDay)
+	return t.clock()
 }
 
-func today() string {
-	return time.Now().Format("02-01-2006")
+func (t Team) today() string {
+	return t.now().Format("02-01-2006")
 }
 
-func (t Team) lowestAccruedDaysAmongstTeamMembers() uint16 {
-	var lowestAccruedDays = uint16(maxUInt16)
+func NewTeam(name string, dbHandle localdb.Store) *Team {
+	return NewTeamWithSettings(name, dbHandle, Settings{})
+}
 
-	history, err := t.RotaHistory()
-	if err != nil {
-		return 1
+func NewTeamWithSettings(name string, dbHandle localdb.Store, settings Settings) *Team {
+	if settings.Strategy == nil {
+		settings.Strategy = leastAccrued{}
 	}
-
-	for _, individualHistory := range history {
-		if individualHistory.DaysAccrued < lowestAccruedDays {
-			lowestAccruedDays = individualHistory.DaysAccrued
-		}
+	if len(settings.Roles) == 0 {
+		settings.Roles = []string{"primary"}
 	}
-
-	// This conditional required while adding the very first team member on a new deployment
-	if lowestAccruedDays == maxUInt16 {
-		return 1
+	if settings.ShiftDays < 1 {
+		settings.ShiftDays = 1
+	}
+	if settings.IsHoliday == nil {
+		settings.IsHoliday = isWeekend
+	}
+	if settings.OnDeadline == "" {
+		settings.OnDeadline = AutoConfirm
+	}
+	cooldown := DefaultCooldown
+	if settings.Cooldown != nil && *settings.Cooldown >= 0 {
+		cooldown = *settings.Cooldown
 	}
-	return lowestAccruedDays
-}
 
-func NewTeam(name string, dbHandle *localdb.LocalDB) *Team {
 	return &Team{
 		name,
 		dbHandle,
+		settings.Strategy,
+		settings.Roles,
+		settings.ShiftDays,
+		settings.IsHoliday,
+		settings.Requirement,
+		settings.Rules,
+		settings.Costs,
+		settings.ConfirmationDeadline,
+		settings.OnDeadline,
+		settings.Onboarding,
+		cooldown,
+		settings.Seed,
+		settings.ExplainPicks,
+		settings.Cadence,
+		nil,
 		keys.NewKey(name),
 	}
 }
@@ -32,3 +32,12 @@ func NewSchedule(cronExpression string, toExecute func()) *Schedule {
 		cron :          cron.New(),
 	}
 }
+
+// Cadence returns when the cron expression fires next after any given time, without scheduling anything
+func Cadence(cronExpression string) (func(time.Time) time.Time, error) {
+	schedule, err := cron.ParseStandard(cronExpression)
+	if err != nil {
+		return nil, err
+	}
+	return schedule.Next, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/nlopes/slack"
+	"github.com/supreethrao/automated-rota-manager/pkg/metrics"
 )
 
 type Messager struct {
@@ -32,6 +33,7 @@ func (m *Messager) SendMessage(messageText string) error {
 	if err != nil {
 		fmt.Println("Failed to post Slack message")
 		fmt.Println(err)
+		metrics.SlackSendFailed(m.Channel)
 		return err
 	}
 